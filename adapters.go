@@ -0,0 +1,235 @@
+package sophie
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/golangplus/errors"
+)
+
+// NewReader returns a Reader reading from r. If r is already a Reader, it is
+// returned directly. Otherwise the bytes are read through a buffer, so more
+// bytes than consumed may be read from r. If r implements io.Seeker, Skip
+// seeks over the bytes not in the buffer instead of reading them.
+func NewReader(r io.Reader) Reader {
+	if sr, ok := r.(Reader); ok {
+		return sr
+	}
+	br := &bufReader{src: r, Reader: bufio.NewReader(r)}
+	br.seeker, _ = r.(io.Seeker)
+	return br
+}
+
+type bufReader struct {
+	src    io.Reader
+	seeker io.Seeker
+	*bufio.Reader
+}
+
+// sophie.Reader interface
+func (br *bufReader) Skip(n int64) (int64, error) {
+	return skipBuffered(br.Reader, br.src, br.seeker, n)
+}
+
+// skipBuffered skips n bytes of a bufio.Reader reading from src. If seeker,
+// the io.Seeker of src, is not nil, bytes not in the buffer are skipped by
+// seeking, and the buffer is reset.
+func skipBuffered(br *bufio.Reader, src io.Reader, seeker io.Seeker, n int64) (int64, error) {
+	buffered := int64(br.Buffered())
+	if seeker == nil || n <= buffered {
+		return discard(br, n)
+	}
+	cur, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return discard(br, n)
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	target := cur + n - buffered
+	if target > end {
+		target = end
+	}
+	if _, err := seeker.Seek(target, io.SeekStart); err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	br.Reset(src)
+	skipped := buffered + target - cur
+	if skipped < n {
+		return skipped, errorsp.WithStacks(io.EOF)
+	}
+	return skipped, nil
+}
+
+// discard skips n bytes by reading them.
+func discard(r *bufio.Reader, n int64) (int64, error) {
+	var skipped int64
+	for skipped < n {
+		chunk := n - skipped
+		if chunk > 1<<30 {
+			chunk = 1 << 30
+		}
+		m, err := r.Discard(int(chunk))
+		skipped += int64(m)
+		if err != nil {
+			return skipped, errorsp.WithStacks(err)
+		}
+	}
+	return skipped, nil
+}
+
+// NewWriter returns a Writer writing to w. If w is already a Writer, it is
+// returned directly. Otherwise the bytes are written to w without buffering,
+// so WriteByte results in a call to w.Write.
+func NewWriter(w io.Writer) Writer {
+	if sw, ok := w.(Writer); ok {
+		return sw
+	}
+	return &byteWriter{Writer: w}
+}
+
+type byteWriter struct {
+	io.Writer
+	arr [1]byte
+}
+
+// io.ByteWriter interface
+func (bw *byteWriter) WriteByte(b byte) error {
+	bw.arr[0] = b
+	_, err := bw.Write(bw.arr[:])
+	return err
+}
+
+// BytesReader is a Reader reading from a byte slice. It keeps the position of
+// the next byte to read, e.g. for locating an error in the input.
+type BytesReader struct {
+	buf []byte
+	pos int
+}
+
+// NewBytesReader returns a *BytesReader reading from buf.
+func NewBytesReader(buf []byte) *BytesReader {
+	return &BytesReader{buf: buf}
+}
+
+// Reset makes the reader read from buf, from the beginning.
+func (br *BytesReader) Reset(buf []byte) {
+	br.buf, br.pos = buf, 0
+}
+
+// Pos returns the number of bytes read or skipped.
+func (br *BytesReader) Pos() int64 {
+	return int64(br.pos)
+}
+
+// Len returns the number of bytes not read.
+func (br *BytesReader) Len() int {
+	return len(br.buf) - br.pos
+}
+
+// io.Reader interface
+func (br *BytesReader) Read(p []byte) (int, error) {
+	if br.pos >= len(br.buf) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, br.buf[br.pos:])
+	br.pos += n
+	return n, nil
+}
+
+// io.ByteReader interface
+func (br *BytesReader) ReadByte() (byte, error) {
+	if br.pos >= len(br.buf) {
+		return 0, io.EOF
+	}
+	b := br.buf[br.pos]
+	br.pos++
+	return b, nil
+}
+
+// io.Seeker interface
+func (br *BytesReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(br.pos)
+	case io.SeekEnd:
+		offset += int64(len(br.buf))
+	case io.SeekStart:
+	default:
+		return 0, errorsp.NewWithStacks("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errorsp.NewWithStacks("negative position %d", offset)
+	}
+	if offset > int64(len(br.buf)) {
+		offset = int64(len(br.buf))
+	}
+	br.pos = int(offset)
+	return offset, nil
+}
+
+// io.ReaderAt interface. It does not change the position.
+func (br *BytesReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errorsp.NewWithStacks("negative offset %d", off)
+	}
+	if off >= int64(len(br.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, br.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// sophie.Reader interface
+func (br *BytesReader) Skip(n int64) (int64, error) {
+	if left := int64(br.Len()); n > left {
+		br.pos = len(br.buf)
+		return left, io.EOF
+	}
+	br.pos += int(n)
+	return n, nil
+}
+
+// BytesWriter is a Writer appending to a byte slice.
+type BytesWriter struct {
+	buf []byte
+}
+
+// NewBytesWriter returns a *BytesWriter appending to buf.
+func NewBytesWriter(buf []byte) *BytesWriter {
+	return &BytesWriter{buf: buf}
+}
+
+// Bytes returns the bytes written, including those in the initial buffer.
+func (bw *BytesWriter) Bytes() []byte {
+	return bw.buf
+}
+
+// Pos returns the number of bytes in the buffer.
+func (bw *BytesWriter) Pos() int64 {
+	return int64(len(bw.buf))
+}
+
+// Reset empties the buffer, keeping its capacity.
+func (bw *BytesWriter) Reset() {
+	bw.buf = bw.buf[:0]
+}
+
+// io.Writer interface
+func (bw *BytesWriter) Write(p []byte) (int, error) {
+	bw.buf = append(bw.buf, p...)
+	return len(p), nil
+}
+
+// io.ByteWriter interface
+func (bw *BytesWriter) WriteByte(b byte) error {
+	bw.buf = append(bw.buf, b)
+	return nil
+}
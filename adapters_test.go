@@ -0,0 +1,137 @@
+package sophie
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+// onlyReader hides all methods but Read.
+type onlyReader struct {
+	io.Reader
+}
+
+// onlyWriter hides all methods but Write.
+type onlyWriter struct {
+	io.Writer
+}
+
+func TestNewReader(t *testing.T) {
+	src := strings.Repeat("0123456789", 1000)
+	for _, seekable := range []bool{false, true} {
+		var in io.Reader = strings.NewReader(src)
+		if !seekable {
+			in = onlyReader{in}
+		}
+		r := NewReader(in)
+
+		b, err := r.ReadByte()
+		assert.NoError(t, err)
+		assert.Equal(t, "b", b, byte('0'))
+
+		// Within the buffer
+		n, err := r.Skip(9)
+		assert.NoError(t, err)
+		assert.Equal(t, "n", n, int64(9))
+
+		// Beyond the buffer
+		n, err = r.Skip(8000)
+		assert.NoError(t, err)
+		assert.Equal(t, "n", n, int64(8000))
+
+		var arr [3]byte
+		_, err = io.ReadFull(r, arr[:])
+		assert.NoError(t, err)
+		assert.Equal(t, "arr", string(arr[:]), "012")
+
+		// Beyond the end
+		n, err = r.Skip(5000)
+		assert.Equal(t, "err", errorsp.Cause(err), io.EOF)
+		assert.Equal(t, "n", n, int64(1987))
+
+		_, err = r.ReadByte()
+		assert.Equal(t, "err", err, io.EOF)
+	}
+
+	// A Reader is returned directly.
+	br := NewBytesReader(nil)
+	assert.Equal(t, "NewReader", NewReader(br), Reader(br))
+}
+
+func TestNewWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(onlyWriter{&buf})
+	assert.NoError(t, String("abc").WriteTo(w))
+	assert.NoError(t, VInt(300).WriteTo(w))
+
+	r := NewReader(onlyReader{&buf})
+	var s String
+	assert.NoError(t, s.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "s", s, String("abc"))
+	var v VInt
+	assert.NoError(t, v.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "v", v, VInt(300))
+
+	// A Writer is returned directly.
+	assert.Equal(t, "NewWriter", NewWriter(&buf), Writer(&buf))
+}
+
+func TestBytesReaderWriter(t *testing.T) {
+	w := NewBytesWriter(make([]byte, 0, 4))
+	assert.NoError(t, String("abc").WriteTo(w))
+	assert.NoError(t, VInt(300).WriteTo(w))
+	assert.Equal(t, "w.Pos()", w.Pos(), int64(6))
+
+	r := NewBytesReader(w.Bytes())
+	var s String
+	assert.NoError(t, s.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "s", s, String("abc"))
+	assert.Equal(t, "r.Pos()", r.Pos(), int64(4))
+	assert.Equal(t, "r.Len()", r.Len(), 2)
+
+	n, err := r.Skip(3)
+	assert.Equal(t, "err", err, io.EOF)
+	assert.Equal(t, "n", n, int64(2))
+	assert.Equal(t, "r.Pos()", r.Pos(), int64(6))
+	_, err = r.ReadByte()
+	assert.Equal(t, "err", err, io.EOF)
+
+	r.Reset(w.Bytes())
+	assert.Equal(t, "r.Pos()", r.Pos(), int64(0))
+	n, err = r.Skip(4)
+	assert.NoError(t, err)
+	var v VInt
+	assert.NoError(t, v.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "v", v, VInt(300))
+
+	w.Reset()
+	assert.Equal(t, "w.Pos()", w.Pos(), int64(0))
+}
+
+func TestBytesReader_Seek(t *testing.T) {
+	r := NewBytesReader([]byte("0123456789"))
+	pos, err := r.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, "pos", pos, int64(3))
+	pos, err = r.Seek(2, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.Equal(t, "pos", pos, int64(5))
+	pos, err = r.Seek(-1, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, "pos", pos, int64(9))
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+
+	var arr [4]byte
+	n, err := r.ReadAt(arr[:], 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "arr", string(arr[:n]), "2345")
+	n, err = r.ReadAt(arr[:], 8)
+	assert.Equal(t, "err", err, io.EOF)
+	assert.Equal(t, "arr", string(arr[:n]), "89")
+	assert.Equal(t, "r.Pos()", r.Pos(), int64(9))
+}
@@ -0,0 +1,93 @@
+package sophie
+
+import (
+	"sync"
+)
+
+// Allocator is an optional interface of a Reader. If the Reader passed to
+// ByteSlice.ReadFrom or RawByteSlice.ReadFrom implements it, the decoded
+// bytes are allocated by Alloc instead of being copied into the capacity of
+// the receiver.
+type Allocator interface {
+	// Alloc returns a byte slice of n bytes, or nil if it does not allocate,
+	// e.g. no Arena is set, in which case the capacity of the receiver is
+	// reused. An Allocator which has allocated must not return nil for lack
+	// of space, since the receiver may still hold its bytes, e.g. of a reset
+	// Arena, which must not be overwritten.
+	Alloc(n int) []byte
+}
+
+// Arena allocates byte slices from a caller-supplied buffer. It is typically
+// used for decoding the kv pairs of one Iterator.Next without allocations,
+// and reset before the next one.
+type Arena struct {
+	buf  []byte
+	used int
+}
+
+// NewArena returns an *Arena allocating from the whole capacity of buf.
+func NewArena(buf []byte) *Arena {
+	return &Arena{buf: buf[:cap(buf)]}
+}
+
+// Allocator interface. Returns nil if the buffer has not enough space.
+func (a *Arena) Alloc(n int) []byte {
+	if n > len(a.buf)-a.used {
+		return nil
+	}
+	bs := a.buf[a.used : a.used+n : a.used+n]
+	a.used += n
+	return bs
+}
+
+// Reset makes the whole buffer available again. Byte slices allocated before
+// will be overwritten by later allocations.
+func (a *Arena) Reset() {
+	a.used = 0
+}
+
+// ArenaReader is a Reader allocating decoded bytes from an Arena.
+type ArenaReader struct {
+	Reader
+	Arena *Arena
+}
+
+// Allocator interface. If the Arena has not enough space, the bytes are
+// allocated by make.
+func (r ArenaReader) Alloc(n int) []byte {
+	if r.Arena == nil {
+		return nil
+	}
+	if bs := r.Arena.Alloc(n); bs != nil {
+		return bs
+	}
+	return make([]byte, n)
+}
+
+// growBytes returns a slice of n bytes, reusing the capacity of buf if
+// possible.
+func growBytes(buf []byte, n int) []byte {
+	if cap(buf) >= n {
+		return buf[:n]
+	}
+	return make([]byte, n)
+}
+
+// allocBytes returns a slice of n bytes allocated by r if it is an Allocator,
+// otherwise by growBytes.
+func allocBytes(r Reader, buf []byte, n int) []byte {
+	if a, ok := r.(Allocator); ok {
+		if bs := a.Alloc(n); bs != nil {
+			return bs
+		}
+	}
+	return growBytes(buf, n)
+}
+
+// Scratch buffers for decoding values which are converted after reading,
+// e.g. String and Time.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
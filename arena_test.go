@@ -0,0 +1,115 @@
+package sophie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestByteSlice_Reuse(t *testing.T) {
+	var data bytesp.Slice
+	assert.NoError(t, ByteSlice("hello").WriteTo(&data))
+
+	ba := make(ByteSlice, 0, 10)
+	p := &ba[:1][0]
+	var bs bytesp.Slice
+	allocs := testing.AllocsPerRun(100, func() {
+		bs = data
+		if err := ba.ReadFrom(&bs, UNKNOWN_LEN); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, "allocs", allocs, 0.0)
+	assert.Equal(t, "ba", ba, ByteSlice("hello"))
+	assert.Equal(t, "&ba[0]", &ba[0], p)
+
+	rba := make(RawByteSlice, 0, 10)
+	raw := bytesp.Slice("hello")
+	allocs = testing.AllocsPerRun(100, func() {
+		bs = raw
+		if err := rba.ReadFrom(&bs, 5); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, "allocs", allocs, 0.0)
+	assert.Equal(t, "rba", rba, RawByteSlice("hello"))
+}
+
+func TestTime_Reuse(t *testing.T) {
+	var data bytesp.Slice
+	assert.NoError(t, Time(time.Unix(1400000000, 0).UTC()).WriteTo(&data))
+
+	var tm Time
+	var bs bytesp.Slice
+	allocs := testing.AllocsPerRun(100, func() {
+		bs = data
+		if err := tm.ReadFrom(&bs, UNKNOWN_LEN); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, "allocs", allocs, 0.0)
+	assert.Equal(t, "tm", time.Time(tm).Unix(), int64(1400000000))
+}
+
+func TestArena(t *testing.T) {
+	buf := make([]byte, 8)
+	arena := NewArena(buf[:0])
+	var data bytesp.Slice
+	assert.NoError(t, ByteSlice("abc").WriteTo(&data))
+	assert.NoError(t, ByteSlice("defgh").WriteTo(&data))
+	assert.NoError(t, ByteSlice("ij").WriteTo(&data))
+
+	r := ArenaReader{Reader: &data, Arena: arena}
+	var a, b, c ByteSlice
+	assert.NoError(t, a.ReadFrom(r, UNKNOWN_LEN))
+	assert.NoError(t, b.ReadFrom(r, UNKNOWN_LEN))
+	assert.NoError(t, c.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "buf", string(buf), "abcdefgh")
+	assert.Equal(t, "a", a, ByteSlice("abc"))
+	assert.Equal(t, "&a[0]", &a[0], &buf[0])
+	assert.Equal(t, "b", b, ByteSlice("defgh"))
+	assert.Equal(t, "&b[0]", &b[0], &buf[3])
+	// Not enough space in the arena
+	assert.Equal(t, "c", c, ByteSlice("ij"))
+	assert.Equal(t, "cap(a)", cap(a), 3)
+
+	arena.Reset()
+	data = bytesp.Slice("xy")
+	var raw RawByteSlice
+	assert.NoError(t, raw.ReadFrom(r, 2))
+	assert.Equal(t, "&raw[0]", &raw[0], &buf[0])
+
+	// Strings never use the arena
+	data = bytesp.Slice("\x02zz")
+	var s String
+	assert.NoError(t, s.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "s", s, String("zz"))
+	assert.Equal(t, "buf", string(buf), "xycdefgh")
+}
+
+func TestArena_Full(t *testing.T) {
+	buf := make([]byte, 8)
+	arena := NewArena(buf[:0])
+	var data bytesp.Slice
+	r := ArenaReader{Reader: &data, Arena: arena}
+
+	var k, v ByteSlice
+	for _, s := range []string{"abcd", "efgh"} {
+		assert.NoError(t, ByteSlice(s).WriteTo(&data))
+	}
+	assert.NoError(t, k.ReadFrom(r, UNKNOWN_LEN))
+	assert.NoError(t, v.ReadFrom(r, UNKNOWN_LEN))
+
+	// v still points into the arena after Reset, so its capacity must not be
+	// reused when the arena is full.
+	arena.Reset()
+	for _, s := range []string{"1234567", "XY"} {
+		assert.NoError(t, ByteSlice(s).WriteTo(&data))
+	}
+	assert.NoError(t, k.ReadFrom(r, UNKNOWN_LEN))
+	assert.NoError(t, v.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "k", k, ByteSlice("1234567"))
+	assert.Equal(t, "v", v, ByteSlice("XY"))
+}
@@ -0,0 +1,114 @@
+package sophie
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golangplus/errors"
+)
+
+// TempFilePrefix is the prefix of the names of the temporary files created by
+// CreateAtomic. Files with names starting with it are hidden, and skipped by
+// readers of directories, e.g. kv.DirInput.
+const TempFilePrefix = "."
+
+// IsTempFile returns true if the file name (not the path) is of a temporary
+// file created by CreateAtomic.
+func IsTempFile(name string) bool {
+	return strings.HasPrefix(name, TempFilePrefix)
+}
+
+var tempFileCounter int64
+
+// CreateAtomic creates a file in fs which is published atomically. The bytes
+// are written to a temporary file in the same directory, which is renamed to
+// fn by Close only if all the writes and the Close of the temporary file
+// succeeded. Otherwise the temporary file is removed and fn is untouched, so
+// readers never see a partially written file. If the WriteCloser created by fs
+// has a Sync method, e.g. that of LocalFS, it is called before the rename, so
+// the file is also complete after a crash.
+func CreateAtomic(fs FileSystem, fn string) (WriteCloser, error) {
+	dir, name := filepath.Split(fn)
+	// The name is kept as the suffix, which may matter, e.g. to CompressedFS.
+	tmp := filepath.Join(dir, fmt.Sprintf("%stmp-%d-%d.%s", TempFilePrefix, os.Getpid(), atomic.AddInt64(&tempFileCounter, 1), name))
+	w, err := fs.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	return &atomicWriter{WriteCloser: w, fs: fs, tmp: tmp, fn: fn}, nil
+}
+
+// syncer is implemented by a WriteCloser able to commit the written bytes to
+// stable storage, e.g. BufferedFileWriter.
+type syncer interface {
+	Sync() error
+}
+
+// atomicWriter writes to a temporary file, and renames it on Close if no
+// error occurred.
+type atomicWriter struct {
+	WriteCloser
+	fs      FileSystem
+	tmp, fn string
+	err     error
+	closed  bool
+}
+
+// io.Writer interface
+func (w *atomicWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.WriteCloser.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// io.ByteWriter interface
+func (w *atomicWriter) WriteByte(b byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.WriteCloser.WriteByte(b); err != nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// io.Closer interface. The file is published only if no error occurred.
+func (w *atomicWriter) Close() error {
+	if w.closed {
+		return errorsp.NewWithStacks("%s has been closed", w.fn)
+	}
+	w.closed = true
+	if s, ok := w.WriteCloser.(syncer); ok && w.err == nil {
+		w.err = s.Sync()
+	}
+	if err := w.WriteCloser.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.err == nil {
+		w.err = w.fs.Rename(w.tmp, w.fn)
+	}
+	if w.err != nil {
+		w.fs.Remove(w.tmp)
+		return errorsp.WithStacksAndMessage(w.err, "%s is not published", w.fn)
+	}
+	return nil
+}
+
+// AtomicFS is a FileSystem whose Create is done by CreateAtomic, so files
+// created through it, e.g. by kv.NewWriter, are published atomically.
+type AtomicFS struct {
+	FileSystem
+}
+
+// FileSystem interface
+func (fs AtomicFS) Create(fn string) (WriteCloser, error) {
+	return CreateAtomic(fs.FileSystem, fn)
+}
@@ -0,0 +1,128 @@
+package sophie
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+// failingFS is a FileSystem whose files fail to be written.
+type failingFS struct {
+	FileSystem
+}
+
+type failingWriter struct {
+	WriteCloser
+}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func (fs failingFS) Create(fn string) (WriteCloser, error) {
+	w, err := fs.FileSystem.Create(fn)
+	return failingWriter{w}, err
+}
+
+func TestCreateAtomic(t *testing.T) {
+	fs := NewMemFS()
+	assert.NoError(t, fs.Mkdir("/d", 0755))
+	fp := FsPath{Fs: fs, Path: "/d/f"}
+
+	w, err := fp.CreateAtomic()
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, String("abc").WriteTo(w))
+	_, err = fp.Stat()
+	assert.True(t, "IsNotExist", os.IsNotExist(err))
+	infos, err := fs.ReadDir("/d")
+	assert.NoError(t, err)
+	assert.Equal(t, "len(infos)", len(infos), 1)
+	assert.True(t, "IsTempFile", IsTempFile(infos[0].Name()))
+
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.Close())
+	fi, err := fp.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, "Size", fi.Size(), int64(4))
+	infos, err = fs.ReadDir("/d")
+	assert.NoError(t, err)
+	assert.Equal(t, "len(infos)", len(infos), 1)
+
+	// A failed write keeps the published file.
+	w, err = AtomicFS{failingFS{fs}}.Create("/d/f")
+	assert.NoErrorOrDie(t, err)
+	_, err = w.Write([]byte("def"))
+	assert.Error(t, err)
+	assert.Error(t, w.Close())
+	fi, err = fp.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, "Size", fi.Size(), int64(4))
+	infos, err = fs.ReadDir("/d")
+	assert.NoError(t, err)
+	assert.Equal(t, "len(infos)", len(infos), 1)
+}
+
+// syncFS is a FileSystem whose files log the calls to Sync and Close.
+type syncFS struct {
+	FileSystem
+	log     *[]string
+	syncErr error
+}
+
+type syncWriter struct {
+	WriteCloser
+	fs syncFS
+}
+
+func (w syncWriter) Sync() error {
+	*w.fs.log = append(*w.fs.log, "Sync")
+	return w.fs.syncErr
+}
+
+func (w syncWriter) Close() error {
+	*w.fs.log = append(*w.fs.log, "Close")
+	return w.WriteCloser.Close()
+}
+
+func (fs syncFS) Create(fn string) (WriteCloser, error) {
+	w, err := fs.FileSystem.Create(fn)
+	return syncWriter{w, fs}, err
+}
+
+func TestCreateAtomic_Sync(t *testing.T) {
+	var log []string
+	fs := syncFS{FileSystem: NewMemFS(), log: &log}
+	w, err := CreateAtomic(fs, "/f")
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "log", log, []string{"Sync", "Close"})
+
+	// A failed Sync keeps the file unpublished.
+	log, fs.syncErr = nil, errors.New("failed")
+	w, err = CreateAtomic(fs, "/g")
+	assert.NoErrorOrDie(t, err)
+	assert.Error(t, w.Close())
+	assert.Equal(t, "log", log, []string{"Sync", "Close"})
+	_, err = fs.Stat("/g")
+	assert.True(t, "IsNotExist", os.IsNotExist(err))
+}
+
+func TestLocalFS_Rename(t *testing.T) {
+	dir := TempDirPath().Join("TestLocalFS_Rename")
+	assert.NoError(t, dir.Mkdir(0755))
+	defer dir.Remove()
+
+	w, err := dir.Join("a").CreateAtomic()
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, VInt(1).WriteTo(w))
+	assert.NoError(t, w.Close())
+
+	assert.NoError(t, dir.Join("a").Rename(dir.Join("b").Path))
+	_, err = dir.Join("a").Stat()
+	assert.True(t, "IsNotExist", os.IsNotExist(err))
+	fi, err := dir.Join("b").Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, "Size", fi.Size(), int64(1))
+}
@@ -0,0 +1,54 @@
+// Package example contains structs with Sophier methods generated by
+// sophiegen.
+package example
+
+import (
+	"time"
+)
+
+//go:generate go run github.com/daviddengcn/sophie/cmd/sophiegen
+
+// Kind is the kind of a Record.
+type Kind int
+
+// Attr is a key-value attribute.
+//
+//sophie:gen
+type Attr struct {
+	Key   string
+	Value []byte
+}
+
+// Record is a struct with all kinds of supported fields.
+//
+//sophie:gen
+type Record struct {
+	Name    string
+	Count   int
+	Delta   int64 `sophie:"svint"`
+	Kind    Kind  `sophie:"vint"`
+	Score   float64
+	Valid   bool
+	Created time.Time
+	Tags    []string
+	Deltas  []int64 `sophie:"svint"`
+	Attrs   []Attr
+	Index   map[string]int
+	Groups  map[int][]string
+	Parent  *Attr
+	Cache   []byte `sophie:"-"`
+	seq     int
+}
+
+// Event is a record whose fields can be added or removed without breaking the
+// existing data.
+//
+//sophie:gen record
+type Event struct {
+	ID     int64  `sophie:"id=1"`
+	Delta  int64  `sophie:"svint,id=2"`
+	Name   string `sophie:"id=4"`
+	Attrs  []Attr `sophie:"id=5"`
+	Parent *Attr  `sophie:"id=6"`
+	Cache  []byte `sophie:"-"`
+}
@@ -0,0 +1,398 @@
+// Code generated by sophiegen. DO NOT EDIT.
+
+package example
+
+import (
+	"sort"
+	"time"
+
+	"github.com/daviddengcn/sophie"
+)
+
+// SophieWriter interface
+func (v Attr) WriteTo(w sophie.Writer) error {
+	if err := sophie.String(v.Key).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.ByteSlice(v.Value).WriteTo(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SophieReader interface
+func (v *Attr) ReadFrom(r sophie.Reader, l int) error {
+	x1 := sophie.String(v.Key)
+	if err := x1.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Key = string(x1)
+	x2 := sophie.ByteSlice(v.Value)
+	if err := x2.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Value = []byte(x2)
+	return nil
+}
+
+// SophieWriter interface
+func (v Record) WriteTo(w sophie.Writer) error {
+	if err := sophie.String(v.Name).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.VInt(v.Count).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.SVInt64(v.Delta).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.VInt(v.Kind).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.Float64(v.Score).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.Bool(v.Valid).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.Time(v.Created).WriteTo(w); err != nil {
+		return err
+	}
+	if err := sophie.WriteStringSlice(w, v.Tags); err != nil {
+		return err
+	}
+	if err := sophie.VInt(len(v.Deltas)).WriteTo(w); err != nil {
+		return err
+	}
+	for _, e3 := range v.Deltas {
+		if err := sophie.SVInt64(e3).WriteTo(w); err != nil {
+			return err
+		}
+	}
+	if err := sophie.VInt(len(v.Attrs)).WriteTo(w); err != nil {
+		return err
+	}
+	for _, e4 := range v.Attrs {
+		if err := e4.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	if err := sophie.VInt(len(v.Index)).WriteTo(w); err != nil {
+		return err
+	}
+	keys5 := make([]string, 0, len(v.Index))
+	for k6 := range v.Index {
+		keys5 = append(keys5, k6)
+	}
+	sort.Slice(keys5, func(i, j int) bool { return keys5[i] < keys5[j] })
+	for _, k6 := range keys5 {
+		if err := sophie.String(k6).WriteTo(w); err != nil {
+			return err
+		}
+		if err := sophie.VInt(v.Index[k6]).WriteTo(w); err != nil {
+			return err
+		}
+	}
+	if err := sophie.VInt(len(v.Groups)).WriteTo(w); err != nil {
+		return err
+	}
+	keys7 := make([]int, 0, len(v.Groups))
+	for k8 := range v.Groups {
+		keys7 = append(keys7, k8)
+	}
+	sort.Slice(keys7, func(i, j int) bool { return keys7[i] < keys7[j] })
+	for _, k8 := range keys7 {
+		if err := sophie.VInt(k8).WriteTo(w); err != nil {
+			return err
+		}
+		if err := sophie.WriteStringSlice(w, v.Groups[k8]); err != nil {
+			return err
+		}
+	}
+	if v.Parent == nil {
+		if err := sophie.Bool(false).WriteTo(w); err != nil {
+			return err
+		}
+	} else {
+		if err := sophie.Bool(true).WriteTo(w); err != nil {
+			return err
+		}
+		if err := (*v.Parent).WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SophieReader interface
+func (v *Record) ReadFrom(r sophie.Reader, l int) error {
+	x9 := sophie.String(v.Name)
+	if err := x9.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Name = string(x9)
+	x10 := sophie.VInt(v.Count)
+	if err := x10.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Count = int(x10)
+	x11 := sophie.SVInt64(v.Delta)
+	if err := x11.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Delta = int64(x11)
+	x12 := sophie.VInt(v.Kind)
+	if err := x12.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Kind = Kind(x12)
+	x13 := sophie.Float64(v.Score)
+	if err := x13.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Score = float64(x13)
+	x14 := sophie.Bool(v.Valid)
+	if err := x14.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Valid = bool(x14)
+	x15 := sophie.Time(v.Created)
+	if err := x15.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Created = time.Time(x15)
+	if err := sophie.ReadStringSlice(r, &v.Tags); err != nil {
+		return err
+	}
+	var n16 sophie.VInt
+	if err := n16.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := sophie.DecodeLimits.CheckCount(int(n16)); err != nil {
+		return err
+	}
+	if cap(v.Deltas) >= int(n16) {
+		v.Deltas = v.Deltas[:n16]
+	} else {
+		v.Deltas = make([]int64, 0, min(int(n16), 1024))
+	}
+	for i17 := 0; i17 < int(n16); i17++ {
+		if i17 == len(v.Deltas) {
+			v.Deltas = append(v.Deltas, *new(int64))
+		}
+		x18 := sophie.SVInt64(v.Deltas[i17])
+		if err := x18.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+			return err
+		}
+		v.Deltas[i17] = int64(x18)
+	}
+	var n19 sophie.VInt
+	if err := n19.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := sophie.DecodeLimits.CheckCount(int(n19)); err != nil {
+		return err
+	}
+	if cap(v.Attrs) >= int(n19) {
+		v.Attrs = v.Attrs[:n19]
+	} else {
+		v.Attrs = make([]Attr, 0, min(int(n19), 1024))
+	}
+	for i20 := 0; i20 < int(n19); i20++ {
+		if i20 == len(v.Attrs) {
+			v.Attrs = append(v.Attrs, *new(Attr))
+		}
+		if err := v.Attrs[i20].ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+			return err
+		}
+	}
+	var n21 sophie.VInt
+	if err := n21.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := sophie.DecodeLimits.CheckCount(int(n21)); err != nil {
+		return err
+	}
+	v.Index = make(map[string]int, min(int(n21), 1024))
+	for i22 := 0; i22 < int(n21); i22++ {
+		var k23 string
+		x25 := sophie.String(k23)
+		if err := x25.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+			return err
+		}
+		k23 = string(x25)
+		var e24 int
+		x26 := sophie.VInt(e24)
+		if err := x26.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+			return err
+		}
+		e24 = int(x26)
+		v.Index[k23] = e24
+	}
+	var n27 sophie.VInt
+	if err := n27.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := sophie.DecodeLimits.CheckCount(int(n27)); err != nil {
+		return err
+	}
+	v.Groups = make(map[int][]string, min(int(n27), 1024))
+	for i28 := 0; i28 < int(n27); i28++ {
+		var k29 int
+		x31 := sophie.VInt(k29)
+		if err := x31.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+			return err
+		}
+		k29 = int(x31)
+		var e30 []string
+		if err := sophie.ReadStringSlice(r, &e30); err != nil {
+			return err
+		}
+		v.Groups[k29] = e30
+	}
+	var p32 sophie.Bool
+	if err := p32.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if !p32 {
+		v.Parent = nil
+	} else {
+		if v.Parent == nil {
+			v.Parent = new(Attr)
+		}
+		if err := (*v.Parent).ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SophieWriter interface
+func (v Event) WriteTo(w sophie.Writer) error {
+	rw := sophie.NewRecordWriter(w)
+	rw.Field(1, func(w sophie.Writer) error {
+		if err := sophie.VInt64(v.ID).WriteTo(w); err != nil {
+			return err
+		}
+		return nil
+	})
+	rw.Field(2, func(w sophie.Writer) error {
+		if err := sophie.SVInt64(v.Delta).WriteTo(w); err != nil {
+			return err
+		}
+		return nil
+	})
+	rw.Field(4, func(w sophie.Writer) error {
+		if err := sophie.String(v.Name).WriteTo(w); err != nil {
+			return err
+		}
+		return nil
+	})
+	rw.Field(5, func(w sophie.Writer) error {
+		if err := sophie.VInt(len(v.Attrs)).WriteTo(w); err != nil {
+			return err
+		}
+		for _, e33 := range v.Attrs {
+			if err := e33.WriteTo(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	rw.Field(6, func(w sophie.Writer) error {
+		if v.Parent == nil {
+			if err := sophie.Bool(false).WriteTo(w); err != nil {
+				return err
+			}
+		} else {
+			if err := sophie.Bool(true).WriteTo(w); err != nil {
+				return err
+			}
+			if err := (*v.Parent).WriteTo(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return rw.End()
+}
+
+// SophieReader interface
+func (v *Event) ReadFrom(r sophie.Reader, l int) error {
+	*v = Event{Cache: v.Cache}
+	rr := sophie.NewRecordReader(r)
+	for rr.Next() {
+		switch rr.Field() {
+		case 1:
+			rr.Read(func(r sophie.Reader, l int) error {
+				x34 := sophie.VInt64(v.ID)
+				if err := x34.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+					return err
+				}
+				v.ID = int64(x34)
+				return nil
+			})
+		case 2:
+			rr.Read(func(r sophie.Reader, l int) error {
+				x35 := sophie.SVInt64(v.Delta)
+				if err := x35.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+					return err
+				}
+				v.Delta = int64(x35)
+				return nil
+			})
+		case 4:
+			rr.Read(func(r sophie.Reader, l int) error {
+				x36 := sophie.String(v.Name)
+				if err := x36.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+					return err
+				}
+				v.Name = string(x36)
+				return nil
+			})
+		case 5:
+			rr.Read(func(r sophie.Reader, l int) error {
+				var n37 sophie.VInt
+				if err := n37.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+					return err
+				}
+				if err := sophie.DecodeLimits.CheckCount(int(n37)); err != nil {
+					return err
+				}
+				if cap(v.Attrs) >= int(n37) {
+					v.Attrs = v.Attrs[:n37]
+				} else {
+					v.Attrs = make([]Attr, 0, min(int(n37), 1024))
+				}
+				for i38 := 0; i38 < int(n37); i38++ {
+					if i38 == len(v.Attrs) {
+						v.Attrs = append(v.Attrs, *new(Attr))
+					}
+					if err := v.Attrs[i38].ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		case 6:
+			rr.Read(func(r sophie.Reader, l int) error {
+				var p39 sophie.Bool
+				if err := p39.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+					return err
+				}
+				if !p39 {
+					v.Parent = nil
+				} else {
+					if v.Parent == nil {
+						v.Parent = new(Attr)
+					}
+					if err := (*v.Parent).ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+	}
+	return rr.Err()
+}
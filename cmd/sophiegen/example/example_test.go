@@ -0,0 +1,198 @@
+package example
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/sophie"
+	"github.com/daviddengcn/sophie/sophietest"
+)
+
+func TestRecord(t *testing.T) {
+	a := Record{
+		Name:    "abc",
+		Count:   123,
+		Delta:   -5,
+		Kind:    Kind(3),
+		Score:   1.5,
+		Valid:   true,
+		Created: time.Unix(1400000000, 5).UTC(),
+		Tags:    []string{"x", "y"},
+		Deltas:  []int64{-1, 0, 1},
+		Attrs:   []Attr{{Key: "k", Value: []byte("v")}},
+		Index:   map[string]int{"a": 1, "b": 2},
+		Groups:  map[int][]string{2: {"c"}, 1: {"a", "b"}},
+		Parent:  &Attr{Key: "p", Value: []byte("pv")},
+		Cache:   []byte("ignored"),
+	}
+	var buf bytesp.Slice
+	assert.NoError(t, a.WriteTo(&buf))
+
+	var b Record
+	assert.NoError(t, b.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "len(buf)", len(buf), 0)
+	a.Cache = nil
+	assert.Equal(t, "b", b, a)
+
+	// Reads into a used Record
+	a = Record{Name: "def", Created: time.Unix(0, 0).UTC()}
+	assert.NoError(t, a.WriteTo(&buf))
+	assert.NoError(t, b.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "b.Name", b.Name, a.Name)
+	assert.Equal(t, "len(b.Tags)", len(b.Tags), 0)
+	assert.Equal(t, "len(b.Index)", len(b.Index), 0)
+	assert.Equal(t, "b.Parent", b.Parent, (*Attr)(nil))
+}
+
+func TestRecord_Compatible(t *testing.T) {
+	// Attr is encoded as a sophie.String followed by a sophie.ByteSlice.
+	var buf bytesp.Slice
+	assert.NoError(t, Attr{Key: "k", Value: []byte("val")}.WriteTo(&buf))
+
+	var expected bytesp.Slice
+	assert.NoError(t, sophie.String("k").WriteTo(&expected))
+	assert.NoError(t, sophie.ByteSlice("val").WriteTo(&expected))
+	assert.Equal(t, "buf", buf, expected)
+}
+
+func TestRecord_Reflect(t *testing.T) {
+	// Generated code writes the same bytes as sophie.StructSophier.
+	a := Record{
+		Name:    "abc",
+		Count:   123,
+		Delta:   -5,
+		Kind:    Kind(3),
+		Created: time.Unix(1400000000, 5).UTC(),
+		Tags:    []string{"x", "y"},
+		Deltas:  []int64{-1, 0, 1},
+		Attrs:   []Attr{{Key: "k", Value: []byte("v")}},
+		Index:   map[string]int{"b": 2, "a": 1},
+		Groups:  map[int][]string{2: {"c"}, 1: {"a", "b"}},
+		Parent:  &Attr{Key: "p", Value: []byte("pv")},
+		seq:     7,
+	}
+	var generated bytesp.Slice
+	assert.NoError(t, a.WriteTo(&generated))
+
+	s, err := sophie.NewStructSophier(&a)
+	assert.NoErrorOrDie(t, err)
+	var reflected bytesp.Slice
+	assert.NoError(t, s.WriteTo(&reflected))
+	assert.Equal(t, "reflected", reflected, generated)
+
+	var b Record
+	s, err = sophie.NewStructSophier(&b)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, s.ReadFrom(&generated, len(generated)))
+	// Unexported fields are ignored by both.
+	a.seq = 0
+	assert.Equal(t, "b", b, a)
+}
+
+// eventV1 is an older version of Event without Delta and Attrs, but with a
+// field removed later.
+type eventV1 struct {
+	ID    int64
+	Name  string
+	Score float64
+}
+
+func (e eventV1) WriteTo(w sophie.Writer) error {
+	rw := sophie.NewRecordWriter(w)
+	rw.Field(1, sophie.VInt64(e.ID).WriteTo)
+	rw.Field(4, sophie.String(e.Name).WriteTo)
+	rw.Float64(3, e.Score)
+	return rw.End()
+}
+
+func (e *eventV1) ReadFrom(r sophie.Reader, l int) error {
+	*e = eventV1{}
+	rr := sophie.NewRecordReader(r)
+	for rr.Next() {
+		switch rr.Field() {
+		case 1:
+			rr.Sophier((*sophie.VInt64)(&e.ID))
+		case 4:
+			rr.Sophier((*sophie.String)(&e.Name))
+		case 3:
+			e.Score = rr.Float64()
+		}
+	}
+	return rr.Err()
+}
+
+func TestEvent(t *testing.T) {
+	a := Event{
+		ID:     12,
+		Delta:  -3,
+		Name:   "abc",
+		Attrs:  []Attr{{Key: "k", Value: []byte("v")}},
+		Parent: &Attr{Key: "p", Value: []byte("pv")},
+		Cache:  []byte("ignored"),
+	}
+	var buf bytesp.Slice
+	assert.NoError(t, a.WriteTo(&buf))
+
+	b := Event{Name: "old", Cache: []byte("kept")}
+	assert.NoError(t, b.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "len(buf)", len(buf), 0)
+	a.Cache = []byte("kept")
+	assert.Equal(t, "b", b, a)
+
+	// New data read by the old version.
+	assert.NoError(t, a.WriteTo(&buf))
+	var v1 eventV1
+	assert.NoError(t, v1.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "len(buf)", len(buf), 0)
+	assert.Equal(t, "v1", v1, eventV1{ID: 12, Name: "abc"})
+
+	// Old data read by the new version.
+	v1 = eventV1{ID: 5, Name: "def", Score: 1.5}
+	assert.NoError(t, v1.WriteTo(&buf))
+	assert.NoError(t, b.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "len(buf)", len(buf), 0)
+	assert.Equal(t, "b", b, Event{ID: 5, Name: "def", Cache: []byte("kept")})
+}
+
+func newRecord() sophie.Sophier {
+	return &Record{}
+}
+
+func newEvent() sophie.Sophier {
+	return &Event{}
+}
+
+var (
+	records = []sophie.SophieWriter{
+		Record{Created: time.Unix(0, 0).UTC()},
+		Record{
+			Name:    "abc",
+			Count:   123,
+			Created: time.Unix(1400000000, 5).UTC(),
+			Tags:    []string{"x"},
+			Index:   map[string]int{"a": 1, "b": 2},
+			Parent:  &Attr{Key: "p"},
+		},
+	}
+	events = []sophie.SophieWriter{
+		Event{},
+		Event{ID: 12, Delta: -3, Name: "abc", Attrs: []Attr{{Key: "k"}}, Parent: &Attr{Key: "p"}},
+	}
+)
+
+func TestConformance(t *testing.T) {
+	sophietest.RoundTrip(t, newRecord, records...)
+	sophietest.Truncated(t, newRecord, records...)
+	sophietest.Golden(t, "testdata/record.golden", records...)
+
+	sophietest.RoundTrip(t, newEvent, events...)
+	sophietest.Truncated(t, newEvent, events...)
+	sophietest.Golden(t, "testdata/event.golden", events...)
+}
+
+func FuzzEvent(f *testing.F) {
+	sophietest.Fuzz(f, newEvent, events...)
+}
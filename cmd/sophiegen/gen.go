@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The comment marking a struct type for generation.
+const genMark = "sophie:gen"
+
+// The maximum number of elements preallocated for a decoded slice or map, as
+// in package sophie. Longer ones grow as the elements are read.
+const maxPrealloc = 1024
+
+// Encoding names used in the sophie field tags, mapped to the sophie types.
+var encodings = map[string]string{
+	"vint":    "VInt",
+	"vint64":  "VInt64",
+	"uvint":   "UVInt64",
+	"svint":   "SVInt64",
+	"int8":    "Int8",
+	"int16":   "Int16",
+	"int32":   "Int32",
+	"int64":   "Int64",
+	"uint8":   "Uint8",
+	"uint16":  "Uint16",
+	"uint32":  "Uint32",
+	"uint64":  "Uint64",
+	"float32": "Float32",
+	"float64": "Float64",
+	"bool":    "Bool",
+	"string":  "String",
+	"bytes":   "ByteSlice",
+	"time":    "Time",
+}
+
+// The default encodings of types without sophie tags.
+var defaultEncodings = map[string]string{
+	"int":       "vint",
+	"int8":      "int8",
+	"int16":     "int16",
+	"int32":     "int32",
+	"int64":     "vint64",
+	"uint":      "uvint",
+	"uint8":     "uint8",
+	"byte":      "uint8",
+	"uint16":    "uint16",
+	"uint32":    "uint32",
+	"uint64":    "uvint",
+	"float32":   "float32",
+	"float64":   "float64",
+	"bool":      "bool",
+	"string":    "string",
+	"[]byte":    "bytes",
+	"time.Time": "time",
+}
+
+// Builtin types can be used as map keys. Keys are sorted before written.
+var orderedKeyTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "byte": true, "uint16": true, "uint32": true,
+	"uint64": true, "float32": true, "float64": true, "string": true,
+}
+
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+	tmp     int
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// newVar returns a new variable name with the prefix.
+func (g *generator) newVar(prefix string) string {
+	g.tmp++
+	return fmt.Sprintf("%s%d", prefix, g.tmp)
+}
+
+func (g *generator) writeErrCheck(call string) {
+	g.printf("if err := %s; err != nil {\nreturn err\n}\n", call)
+}
+
+// basicEncoding returns the sophie type name of typ with tag encoding enc, or
+// "" if it is not encoded as a basic sophie type.
+func basicEncoding(typ ast.Expr, enc string) (string, error) {
+	switch typ.(type) {
+	case *ast.ArrayType, *ast.MapType, *ast.StarExpr:
+		// enc, if any, applies to the elements
+		if types.ExprString(typ) != "[]byte" {
+			return "", nil
+		}
+	}
+	if enc == "" {
+		enc = defaultEncodings[types.ExprString(typ)]
+		if enc == "" {
+			return "", nil
+		}
+	}
+	st, ok := encodings[enc]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding %q", enc)
+	}
+	return st, nil
+}
+
+// genWrite generates the code writing expr of type typ.
+func (g *generator) genWrite(expr string, typ ast.Expr, enc string) error {
+	st, err := basicEncoding(typ, enc)
+	if err != nil {
+		return err
+	}
+	if st != "" {
+		if st == "Time" {
+			g.imports["time"] = true
+		}
+		g.writeErrCheck(fmt.Sprintf("sophie.%s(%s).WriteTo(w)", st, expr))
+		return nil
+	}
+	if types.ExprString(typ) == "[]string" && (enc == "" || enc == "string") {
+		g.writeErrCheck(fmt.Sprintf("sophie.WriteStringSlice(w, %s)", expr))
+		return nil
+	}
+	switch t := typ.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		// Assumed to be a Sophier
+		g.writeErrCheck(fmt.Sprintf("%s.WriteTo(w)", expr))
+		return nil
+
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Errorf("arrays are not supported: %s", types.ExprString(typ))
+		}
+		g.writeErrCheck(fmt.Sprintf("sophie.VInt(len(%s)).WriteTo(w)", expr))
+		e := g.newVar("e")
+		g.printf("for _, %s := range %s {\n", e, expr)
+		if err := g.genWrite(e, t.Elt, enc); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+
+	case *ast.MapType:
+		keyType := types.ExprString(t.Key)
+		if !orderedKeyTypes[keyType] {
+			return fmt.Errorf("unsupported map key type %s", keyType)
+		}
+		g.imports["sort"] = true
+		g.writeErrCheck(fmt.Sprintf("sophie.VInt(len(%s)).WriteTo(w)", expr))
+		keys, k := g.newVar("keys"), g.newVar("k")
+		g.printf("%s := make([]%s, 0, len(%s))\n", keys, keyType, expr)
+		g.printf("for %s := range %s {\n%s = append(%s, %s)\n}\n", k, expr, keys, keys, k)
+		g.printf("sort.Slice(%s, func(i, j int) bool { return %s[i] < %s[j] })\n", keys, keys, keys)
+		g.printf("for _, %s := range %s {\n", k, keys)
+		if err := g.genWrite(k, t.Key, ""); err != nil {
+			return err
+		}
+		if err := g.genWrite(fmt.Sprintf("%s[%s]", expr, k), t.Value, enc); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+
+	case *ast.StarExpr:
+		g.printf("if %s == nil {\n", expr)
+		g.writeErrCheck("sophie.Bool(false).WriteTo(w)")
+		g.printf("} else {\n")
+		g.writeErrCheck("sophie.Bool(true).WriteTo(w)")
+		if err := g.genWrite("(*"+expr+")", t.X, enc); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", types.ExprString(typ))
+}
+
+// genRead generates the code reading to expr of type typ. expr must be
+// addressable.
+func (g *generator) genRead(expr string, typ ast.Expr, enc string) error {
+	st, err := basicEncoding(typ, enc)
+	if err != nil {
+		return err
+	}
+	if st != "" {
+		if st == "Time" {
+			g.imports["time"] = true
+		}
+		x := g.newVar("x")
+		g.printf("%s := sophie.%s(%s)\n", x, st, expr)
+		g.writeErrCheck(fmt.Sprintf("%s.ReadFrom(r, sophie.UNKNOWN_LEN)", x))
+		g.printf("%s = %s(%s)\n", expr, types.ExprString(typ), x)
+		return nil
+	}
+	if types.ExprString(typ) == "[]string" && (enc == "" || enc == "string") {
+		g.writeErrCheck(fmt.Sprintf("sophie.ReadStringSlice(r, &%s)", expr))
+		return nil
+	}
+	switch t := typ.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		// Assumed to be a Sophier
+		g.writeErrCheck(fmt.Sprintf("%s.ReadFrom(r, sophie.UNKNOWN_LEN)", expr))
+		return nil
+
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Errorf("arrays are not supported: %s", types.ExprString(typ))
+		}
+		n := g.newVar("n")
+		g.printf("var %s sophie.VInt\n", n)
+		g.writeErrCheck(fmt.Sprintf("%s.ReadFrom(r, sophie.UNKNOWN_LEN)", n))
+		g.writeErrCheck(fmt.Sprintf("sophie.DecodeLimits.CheckCount(int(%s))", n))
+		g.printf("if cap(%s) >= int(%s) {\n%s = %s[:%s]\n} else {\n%s = make(%s, 0, min(int(%s), %d))\n}\n",
+			expr, n, expr, expr, n, expr, types.ExprString(typ), n, maxPrealloc)
+		i := g.newVar("i")
+		g.printf("for %s := 0; %s < int(%s); %s++ {\n", i, i, n, i)
+		g.printf("if %s == len(%s) {\n%s = append(%s, *new(%s))\n}\n", i, expr, expr, expr, types.ExprString(t.Elt))
+		if err := g.genRead(fmt.Sprintf("%s[%s]", expr, i), t.Elt, enc); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+
+	case *ast.MapType:
+		keyType := types.ExprString(t.Key)
+		if !orderedKeyTypes[keyType] {
+			return fmt.Errorf("unsupported map key type %s", keyType)
+		}
+		n := g.newVar("n")
+		g.printf("var %s sophie.VInt\n", n)
+		g.writeErrCheck(fmt.Sprintf("%s.ReadFrom(r, sophie.UNKNOWN_LEN)", n))
+		g.writeErrCheck(fmt.Sprintf("sophie.DecodeLimits.CheckCount(int(%s))", n))
+		g.printf("%s = make(%s, min(int(%s), %d))\n", expr, types.ExprString(typ), n, maxPrealloc)
+		i, k, e := g.newVar("i"), g.newVar("k"), g.newVar("e")
+		g.printf("for %s := 0; %s < int(%s); %s++ {\n", i, i, n, i)
+		g.printf("var %s %s\n", k, keyType)
+		if err := g.genRead(k, t.Key, ""); err != nil {
+			return err
+		}
+		g.printf("var %s %s\n", e, types.ExprString(t.Value))
+		if err := g.genRead(e, t.Value, enc); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n}\n", expr, k, e)
+		return nil
+
+	case *ast.StarExpr:
+		p := g.newVar("p")
+		g.printf("var %s sophie.Bool\n", p)
+		g.writeErrCheck(fmt.Sprintf("%s.ReadFrom(r, sophie.UNKNOWN_LEN)", p))
+		g.printf("if !%s {\n%s = nil\n} else {\n", p, expr)
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, types.ExprString(t.X))
+		if err := g.genRead("(*"+expr+")", t.X, enc); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", types.ExprString(typ))
+}
+
+type genField struct {
+	name string
+	typ  ast.Expr
+	enc  string
+	// The field ID of a record field
+	id int
+}
+
+// parseTag parses a sophie tag of the form "encoding", "id=N" or
+// "encoding,id=N".
+func parseTag(tag string) (enc string, id int, err error) {
+	for i, part := range strings.Split(tag, ",") {
+		if !strings.HasPrefix(part, "id=") {
+			if i > 0 {
+				return "", 0, fmt.Errorf("unknown tag option %q", part)
+			}
+			enc = part
+			continue
+		}
+		if id, err = strconv.Atoi(strings.TrimPrefix(part, "id=")); err != nil || id <= 0 {
+			return "", 0, fmt.Errorf("invalid field ID %q", part)
+		}
+	}
+	return enc, id, nil
+}
+
+// structFields returns the fields to be serialized of a struct type, and the
+// names of the ignored fields, i.e. those tagged with "-" and the unexported
+// ones.
+func structFields(st *ast.StructType) (fields []genField, ignored []string, err error) {
+	for _, f := range st.Fields.List {
+		var enc string
+		var id int
+		if f.Tag != nil {
+			tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
+			if enc, id, err = parseTag(tag.Get("sophie")); err != nil {
+				return nil, nil, err
+			}
+		}
+		var names []string
+		if len(f.Names) == 0 {
+			// Embedded field
+			name := types.ExprString(f.Type)
+			name = name[strings.LastIndex(name, ".")+1:]
+			names = append(names, strings.TrimPrefix(name, "*"))
+		}
+		for _, n := range f.Names {
+			if n.Name != "_" {
+				names = append(names, n.Name)
+			}
+		}
+		if enc == "-" {
+			ignored = append(ignored, names...)
+			continue
+		}
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				// Ignored as by sophie.StructSophier
+				ignored = append(ignored, name)
+				continue
+			}
+			fields = append(fields, genField{name: name, typ: f.Type, enc: enc, id: id})
+		}
+	}
+	return fields, ignored, nil
+}
+
+func (g *generator) genType(name string, st *ast.StructType, record bool) error {
+	fields, ignored, err := structFields(st)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if record {
+		return g.genRecordType(name, fields, ignored)
+	}
+	for _, f := range fields {
+		if f.id != 0 {
+			return fmt.Errorf("%s.%s: field IDs can only be used in record types", name, f.name)
+		}
+	}
+	g.printf("// SophieWriter interface\n")
+	g.printf("func (v %s) WriteTo(w sophie.Writer) error {\n", name)
+	for _, f := range fields {
+		if err := g.genWrite("v."+f.name, f.typ, f.enc); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+	}
+	g.printf("return nil\n}\n\n")
+
+	g.printf("// SophieReader interface\n")
+	g.printf("func (v *%s) ReadFrom(r sophie.Reader, l int) error {\n", name)
+	for _, f := range fields {
+		if err := g.genRead("v."+f.name, f.typ, f.enc); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+	}
+	g.printf("return nil\n}\n\n")
+	return nil
+}
+
+// genRecordType generates the methods of a record type, whose fields are
+// written as sophie.WireBytes fields of a record by sophie.RecordWriter.
+func (g *generator) genRecordType(name string, fields []genField, ignored []string) error {
+	ids := make(map[int]string)
+	for _, f := range fields {
+		if f.id == 0 {
+			return fmt.Errorf("%s.%s: missing field ID in a record type", name, f.name)
+		}
+		if other, ok := ids[f.id]; ok {
+			return fmt.Errorf("%s.%s: field ID %d has been used by %s", name, f.name, f.id, other)
+		}
+		ids[f.id] = f.name
+	}
+
+	g.printf("// SophieWriter interface\n")
+	g.printf("func (v %s) WriteTo(w sophie.Writer) error {\n", name)
+	g.printf("rw := sophie.NewRecordWriter(w)\n")
+	for _, f := range fields {
+		g.printf("rw.Field(%d, func(w sophie.Writer) error {\n", f.id)
+		if err := g.genWrite("v."+f.name, f.typ, f.enc); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+		g.printf("return nil\n})\n")
+	}
+	g.printf("return rw.End()\n}\n\n")
+
+	g.printf("// SophieReader interface\n")
+	g.printf("func (v *%s) ReadFrom(r sophie.Reader, l int) error {\n", name)
+	// Fields not in the data are reset, ignored fields are kept.
+	var kept []string
+	for _, n := range ignored {
+		kept = append(kept, fmt.Sprintf("%s: v.%s", n, n))
+	}
+	g.printf("*v = %s{%s}\n", name, strings.Join(kept, ", "))
+	g.printf("rr := sophie.NewRecordReader(r)\n")
+	g.printf("for rr.Next() {\nswitch rr.Field() {\n")
+	for _, f := range fields {
+		g.printf("case %d:\n", f.id)
+		g.printf("rr.Read(func(r sophie.Reader, l int) error {\n")
+		if err := g.genRead("v."+f.name, f.typ, f.enc); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+		g.printf("return nil\n})\n")
+	}
+	g.printf("}\n}\n")
+	g.printf("return rr.Err()\n}\n\n")
+	return nil
+}
+
+// genMarkOf returns whether any line of the comment groups is genMark, and
+// whether the mark has the record option, i.e. "//sophie:gen record".
+func genMarkOf(groups ...*ast.CommentGroup) (marked, record bool) {
+	for _, cg := range groups {
+		if cg == nil {
+			continue
+		}
+		for _, c := range cg.List {
+			fields := strings.Fields(strings.TrimPrefix(c.Text, "//"))
+			if len(fields) == 0 || fields[0] != genMark {
+				continue
+			}
+			return true, len(fields) > 1 && fields[1] == "record"
+		}
+	}
+	return false, false
+}
+
+// generate returns the formatted source code of the Sophier methods for the
+// struct types in file. If typeNames is empty, the types marked with a
+// "//sophie:gen" comment are generated.
+func generate(file *ast.File, typeNames []string) ([]byte, error) {
+	wanted := make(map[string]bool)
+	for _, name := range typeNames {
+		wanted[name] = true
+	}
+
+	g := &generator{imports: make(map[string]bool)}
+	found := 0
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			marked, record := genMarkOf(gd.Doc, ts.Doc)
+			if len(typeNames) > 0 {
+				if !wanted[ts.Name.Name] {
+					continue
+				}
+			} else if !marked {
+				continue
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a struct type", ts.Name.Name)
+			}
+			if err := g.genType(ts.Name.Name, st, record); err != nil {
+				return nil, err
+			}
+			found++
+		}
+	}
+	if found == 0 {
+		return nil, fmt.Errorf("no types to generate in package %s", file.Name.Name)
+	}
+	if len(typeNames) > 0 && found < len(typeNames) {
+		return nil, fmt.Errorf("%d of types %v not found", len(typeNames)-found, typeNames)
+	}
+
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by sophiegen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\nimport (\n", file.Name.Name)
+	for _, imp := range imports {
+		fmt.Fprintf(&src, "%q\n", imp)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&src, "\n")
+	}
+	fmt.Fprintf(&src, "%q\n)\n\n", "github.com/daviddengcn/sophie")
+	src.Write(g.buf.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code failed: %v\n%s", err, src.Bytes())
+	}
+	return out, nil
+}
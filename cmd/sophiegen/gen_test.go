@@ -0,0 +1,80 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestGenerate_Example(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "example/example.go", nil, parser.ParseComments)
+	assert.NoErrorOrDie(t, err)
+
+	src, err := generate(file, nil)
+	assert.NoErrorOrDie(t, err)
+
+	exp, err := ioutil.ReadFile("example/example_sophie.go")
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "src", string(src), string(exp))
+}
+
+func generateString(t *testing.T, src string, types ...string) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "src.go", src, parser.ParseComments)
+	assert.NoErrorOrDie(t, err)
+	out, err := generate(file, types)
+	return string(out), err
+}
+
+func TestGenerate_Types(t *testing.T) {
+	out, err := generateString(t, `package p
+type A struct {
+	S string
+}
+type B struct {
+	N int
+}
+`, "B")
+	assert.NoError(t, err)
+	assert.Should(t, strings.Contains(out, "func (v B) WriteTo(w sophie.Writer) error {"), out)
+	assert.Should(t, !strings.Contains(out, "func (v A)"), out)
+
+	_, err = generateString(t, `package p
+type A struct{}
+`, "A", "C")
+	assert.Error(t, err)
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	for _, src := range []string{
+		// No marked types
+		"package p\ntype A struct{}\n",
+		// Not a struct
+		"package p\n//sophie:gen\ntype A int\n",
+		// Arrays
+		"package p\n//sophie:gen\ntype A struct {\nF [3]int\n}\n",
+		// Map keys
+		"package p\n//sophie:gen\ntype A struct {\nF map[K]int\n}\n",
+		// Unknown encoding
+		"package p\n//sophie:gen\ntype A struct {\nF int `sophie:\"what\"`\n}\n",
+		// Chan
+		"package p\n//sophie:gen\ntype A struct {\nF chan int\n}\n",
+		// Field IDs in a non-record type
+		"package p\n//sophie:gen\ntype A struct {\nF int `sophie:\"id=1\"`\n}\n",
+		// Missing field ID
+		"package p\n//sophie:gen record\ntype A struct {\nF int\n}\n",
+		// Duplicate field IDs
+		"package p\n//sophie:gen record\ntype A struct {\nF int `sophie:\"id=1\"`\nG int `sophie:\"vint,id=1\"`\n}\n",
+		// Invalid field ID
+		"package p\n//sophie:gen record\ntype A struct {\nF int `sophie:\"id=0\"`\n}\n",
+		// Unknown tag option
+		"package p\n//sophie:gen\ntype A struct {\nF int `sophie:\"vint,what\"`\n}\n",
+	} {
+		_, err := generateString(t, src)
+		assert.Error(t, err)
+	}
+}
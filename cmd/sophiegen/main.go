@@ -0,0 +1,136 @@
+/*
+Sophiegen generates the WriteTo/ReadFrom methods of Go structs, making them
+Sophiers without reflections.
+
+Usage:
+
+	sophiegen [-type T1,T2] [-output file] [files...]
+
+It is typically invoked by go generate with a directive in the source file:
+
+	//go:generate sophiegen
+
+and struct types marked with a comment line:
+
+	//sophie:gen
+	type Record struct {
+	    Name  string
+	    Count int
+	    Delta int64     `sophie:"svint"`
+	    Tags  []string
+	    Attrs map[string]Value
+	    Next  *Record
+	    Cache []byte    `sophie:"-"`
+	}
+
+If no files are specified, $GOFILE set by go generate is used. The code is
+written to <file>_sophie.go unless -output is specified.
+
+Fields are serialized in their declaration order. Builtin types are encoded
+using the corresponding sophie types, e.g. int as sophie.VInt, string as
+sophie.String, time.Time as sophie.Time. The encoding can be specified with a
+sophie tag, which applies to the elements for slices and pointers, and to the
+values for maps:
+
+	vint vint64 uvint svint int8 int16 int32 int64 uint8 uint16 uint32 uint64
+	float32 float64 bool string bytes time
+
+Slices and maps are prefixed with a sophie.VInt of the length, which is checked
+against sophie.DecodeLimits when reading. Map entries are written in the order
+of their keys. Pointers are prefixed with a
+sophie.Bool indicating whether it is non-nil. Fields of other named types are
+assumed to be Sophiers, e.g. structs generated by sophiegen.
+
+A field tagged with `sophie:"-"` is ignored, and so is an unexported field.
+
+A struct marked with "//sophie:gen record" is serialized as a record by
+sophie.RecordWriter, so that fields can be added or removed later without
+breaking the existing data. Each field needs a unique positive ID, which must
+never be reused, specified by the id option of the tag:
+
+	//sophie:gen record
+	type Event struct {
+	    ID    int64  `sophie:"id=1"`
+	    Delta int64  `sophie:"svint,id=2"`
+	    Name  string `sophie:"id=3"`
+	}
+
+Each field is written as a sophie.WireBytes field containing its usual
+encoding. Fields not in the data are reset to zero values when reading, except
+the ignored ones.
+
+Except for record types, the generated code writes the same bytes as
+sophie.StructSophier, the reflection-based adapter, so a struct prototyped with
+sophie.StructSophier can switch to the generated methods without rewriting the
+data.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; default: the struct types marked with //sophie:gen")
+	output    = flag.String("output", "", "output file name; default: <file>_sophie.go")
+)
+
+func outputName(fn string) string {
+	if *output != "" {
+		return *output
+	}
+	return strings.TrimSuffix(fn, ".go") + "_sophie.go"
+}
+
+func genFile(fn string, types []string) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, fn, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	src, err := generate(file, types)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fn, err)
+	}
+	return ioutil.WriteFile(outputName(fn), src, 0644)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("sophiegen: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sophiegen [flags] [files...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		if fn := os.Getenv("GOFILE"); fn != "" {
+			files = []string{fn}
+		}
+	}
+	if len(files) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output != "" && len(files) > 1 {
+		log.Fatal("-output cannot be used with multiple files")
+	}
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+	for _, fn := range files {
+		if err := genFile(fn, types); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
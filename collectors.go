@@ -0,0 +1,209 @@
+package sophie
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+)
+
+type teeCollector []CollectCloser
+
+// TeeCollector returns a CollectCloser collecting every pair to all of cs, in
+// order. Collect stops at the first error. Close closes all of cs and returns
+// the first error.
+func TeeCollector(cs ...CollectCloser) CollectCloser {
+	return teeCollector(cs)
+}
+
+// Collector interface
+func (tc teeCollector) Collect(key, val SophieWriter) error {
+	for _, c := range tc {
+		if err := c.Collect(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// io.Closer interface
+func (tc teeCollector) Close() error {
+	return closeAll(tc)
+}
+
+type partitionCollector struct {
+	cs  []CollectCloser
+	buf bytesp.Slice
+}
+
+// PartitionCollector returns a CollectCloser collecting every pair to one of
+// cs, chosen by the FNV-1a hash of the serialized bytes of the key, so pairs
+// of the same key go to the same collector. Close closes all of cs and returns
+// the first error. It is not safe for concurrent use.
+func PartitionCollector(cs ...CollectCloser) CollectCloser {
+	return &partitionCollector{cs: cs}
+}
+
+// Collector interface
+func (pc *partitionCollector) Collect(key, val SophieWriter) error {
+	if len(pc.cs) == 0 {
+		return errorsp.NewWithStacks("no collectors to partition to")
+	}
+	pc.buf.Reset()
+	if err := key.WriteTo(&pc.buf); err != nil {
+		return err
+	}
+	h := fnv.New32a()
+	h.Write(pc.buf)
+	return pc.cs[h.Sum32()%uint32(len(pc.cs))].Collect(key, val)
+}
+
+// io.Closer interface
+func (pc *partitionCollector) Close() error {
+	return closeAll(pc.cs)
+}
+
+// CountingCollector is a CollectCloser counting the pairs collected to the
+// embedded CollectCloser. Only successfully collected pairs are counted. It is
+// not safe for concurrent use.
+type CountingCollector struct {
+	CollectCloser
+	// The number of pairs collected.
+	Records int64
+	// The total serialized bytes of the keys and values collected, computed by
+	// EncodedLen.
+	Bytes int64
+}
+
+// NewCountingCollector returns a *CountingCollector collecting to c.
+func NewCountingCollector(c CollectCloser) *CountingCollector {
+	return &CountingCollector{CollectCloser: c}
+}
+
+// Collector interface
+func (cc *CountingCollector) Collect(key, val SophieWriter) error {
+	if err := cc.CollectCloser.Collect(key, val); err != nil {
+		return err
+	}
+	cc.Records++
+	cc.Bytes += int64(EncodedLen(key) + EncodedLen(val))
+	return nil
+}
+
+type filterCollector struct {
+	CollectCloser
+	pred func(key, val SophieWriter) bool
+}
+
+// FilterCollector returns a CollectCloser collecting to c the pairs for which
+// pred returns true. Closing it closes c.
+func FilterCollector(c CollectCloser, pred func(key, val SophieWriter) bool) CollectCloser {
+	return &filterCollector{CollectCloser: c, pred: pred}
+}
+
+// Collector interface
+func (fc *filterCollector) Collect(key, val SophieWriter) error {
+	if !fc.pred(key, val) {
+		return nil
+	}
+	return fc.CollectCloser.Collect(key, val)
+}
+
+type synchronizedCollector struct {
+	mu sync.Mutex
+	c  CollectCloser
+}
+
+// SynchronizedCollector returns a CollectCloser whose Collect and Close can be
+// called concurrently, by serializing the calls to c.
+func SynchronizedCollector(c CollectCloser) CollectCloser {
+	return &synchronizedCollector{c: c}
+}
+
+// Collector interface
+func (sc *synchronizedCollector) Collect(key, val SophieWriter) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.c.Collect(key, val)
+}
+
+// io.Closer interface
+func (sc *synchronizedCollector) Close() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.c.Close()
+}
+
+// BufferedCollector is a CollectCloser buffering the serialized pairs, and
+// collecting them to the embedded CollectCloser in order once Size bytes are
+// buffered, or by Flush or Close. The keys and values are collected as
+// RawByteSlices of their serialized bytes, so the underlying collector must
+// write them as they are, e.g. a kv.Writer. Once collecting to it fails, the
+// error is returned by all later calls. It is not safe for concurrent use.
+type BufferedCollector struct {
+	CollectCloser
+	// The number of bytes buffered to trigger a Flush.
+	Size int
+
+	buf  bytesp.Slice
+	ends []int // ends of keys and values in buf
+	err  error
+}
+
+// NewBufferedCollector returns a *BufferedCollector collecting to c once size
+// bytes are buffered.
+func NewBufferedCollector(c CollectCloser, size int) *BufferedCollector {
+	return &BufferedCollector{CollectCloser: c, Size: size}
+}
+
+// Collector interface
+func (bc *BufferedCollector) Collect(key, val SophieWriter) error {
+	if bc.err != nil {
+		return bc.err
+	}
+	start := len(bc.buf)
+	if err := key.WriteTo(&bc.buf); err != nil {
+		bc.buf = bc.buf[:start]
+		return err
+	}
+	keyEnd := len(bc.buf)
+	if err := val.WriteTo(&bc.buf); err != nil {
+		bc.buf = bc.buf[:start]
+		return err
+	}
+	bc.ends = append(bc.ends, keyEnd, len(bc.buf))
+	if len(bc.buf) >= bc.Size {
+		return bc.Flush()
+	}
+	return nil
+}
+
+// Flush collects the buffered pairs to the embedded CollectCloser.
+func (bc *BufferedCollector) Flush() error {
+	if bc.err != nil {
+		return bc.err
+	}
+	start := 0
+	for i := 0; i < len(bc.ends); i += 2 {
+		keyEnd, valEnd := bc.ends[i], bc.ends[i+1]
+		if err := bc.CollectCloser.Collect(RawByteSlice(bc.buf[start:keyEnd]), RawByteSlice(bc.buf[keyEnd:valEnd])); err != nil {
+			bc.err = err
+			return err
+		}
+		start = valEnd
+	}
+	bc.buf, bc.ends = bc.buf[:0], bc.ends[:0]
+	return nil
+}
+
+// io.Closer interface. The buffered pairs are flushed before closing the
+// embedded CollectCloser, which is closed even if the flush failed. The first
+// error is returned.
+func (bc *BufferedCollector) Close() error {
+	err := bc.Flush()
+	if cerr := bc.CollectCloser.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package sophie
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+)
+
+// sliceCollector returns a CollectCloser appending keys to *ks, and returning
+// closeErr on Close.
+func sliceCollector(ks *[]int, closeErr error) CollectCloser {
+	return &CollectCloserStruct{
+		CollectF: func(key, val SophieWriter) error {
+			*ks = append(*ks, int(key.(VInt)))
+			return nil
+		},
+		CloseF: func() error {
+			return closeErr
+		},
+	}
+}
+
+func TestTeeCollector(t *testing.T) {
+	var ks1, ks2 []int
+	errClose := errors.New("close")
+	c := TeeCollector(sliceCollector(&ks1, nil), sliceCollector(&ks2, errClose))
+	assert.NoError(t, c.Collect(VInt(1), VInt(1)))
+	assert.NoError(t, c.Collect(VInt(2), VInt(2)))
+	assert.Equal(t, "ks1", ks1, []int{1, 2})
+	assert.Equal(t, "ks2", ks2, []int{1, 2})
+	assert.Equal(t, "err", c.Close(), errClose)
+}
+
+func TestPartitionCollector(t *testing.T) {
+	ks := make([][]int, 3)
+	var cs []CollectCloser
+	for i := range ks {
+		cs = append(cs, sliceCollector(&ks[i], nil))
+	}
+	c := PartitionCollector(cs...)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, c.Collect(VInt(i%10), VInt(i)))
+	}
+	assert.NoError(t, c.Close())
+
+	total := 0
+	part := make(map[int]int)
+	for i, pks := range ks {
+		total += len(pks)
+		assert.True(t, "len(ks[i]) > 0", len(pks) > 0)
+		for _, k := range pks {
+			if p, ok := part[k]; ok {
+				assert.Equal(t, "partition", i, p)
+			}
+			part[k] = i
+		}
+	}
+	assert.Equal(t, "total", total, 100)
+
+	assert.Error(t, PartitionCollector().Collect(VInt(1), VInt(1)))
+}
+
+func TestCountingFilterCollector(t *testing.T) {
+	var ks []int
+	cc := NewCountingCollector(sliceCollector(&ks, nil))
+	c := FilterCollector(cc, func(key, val SophieWriter) bool {
+		return key.(VInt) > 100
+	})
+	assert.NoError(t, c.Collect(VInt(1), VInt(1)))
+	assert.NoError(t, c.Collect(VInt(200), String("abc")))
+	assert.NoError(t, c.Close())
+	assert.Equal(t, "ks", ks, []int{200})
+	assert.Equal(t, "Records", cc.Records, int64(1))
+	assert.Equal(t, "Bytes", cc.Bytes, int64(2+4))
+}
+
+func TestSynchronizedCollector(t *testing.T) {
+	var ks []int
+	c := SynchronizedCollector(sliceCollector(&ks, nil))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Collect(VInt(i), VInt(j))
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.NoError(t, c.Close())
+	assert.Equal(t, "len(ks)", len(ks), 1000)
+}
+
+func TestBufferedCollector(t *testing.T) {
+	var out bytesp.Slice
+	var collected int
+	c := NewBufferedCollector(&CollectCloserStruct{
+		CollectF: func(key, val SophieWriter) error {
+			collected++
+			if err := key.WriteTo(&out); err != nil {
+				return err
+			}
+			return val.WriteTo(&out)
+		},
+		CloseF: func() error {
+			return nil
+		},
+	}, 10)
+	var exp bytesp.Slice
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, c.Collect(VInt(i), String("ab")))
+		assert.NoError(t, VInt(i).WriteTo(&exp))
+		assert.NoError(t, String("ab").WriteTo(&exp))
+	}
+	// 3 pairs of 4 bytes are flushed once 10 bytes are buffered.
+	assert.Equal(t, "collected", collected, 3)
+	assert.NoError(t, c.Close())
+	assert.Equal(t, "collected", collected, 4)
+	assert.Equal(t, "out", out, exp)
+
+	// Errors of collecting are kept, and returned by Close.
+	errCollect, closed := errors.New("collect"), false
+	c = NewBufferedCollector(&CollectCloserStruct{
+		CollectF: func(key, val SophieWriter) error {
+			return errCollect
+		},
+		CloseF: func() error {
+			closed = true
+			return nil
+		},
+	}, 1)
+	assert.Equal(t, "err", c.Collect(VInt(1), VInt(1)), errCollect)
+	assert.Equal(t, "err", c.Collect(VInt(2), VInt(2)), errCollect)
+	assert.Equal(t, "err", c.Close(), errCollect)
+	assert.True(t, "closed", closed)
+}
@@ -0,0 +1,188 @@
+package sophie
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/golangplus/errors"
+)
+
+// Compression is a compression format of a CompressedFS.
+type Compression struct {
+	// The suffix of the names of the files in this format, e.g. ".gz".
+	Suffix string
+	// NewWriter returns an io.WriteCloser compressing the bytes to w. Close
+	// should not close w.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns an io.ReadCloser decompressing the bytes from r. Close
+	// should not close r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	// Gzip is the Compression of the gzip format, with suffix ".gz".
+	Gzip = &Compression{
+		Suffix: ".gz",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	}
+	// Zlib is the Compression of the zlib format, with suffix ".zlib".
+	Zlib = &Compression{
+		Suffix: ".zlib",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	}
+	// Flate is the Compression of the raw DEFLATE format, with suffix
+	// ".deflate".
+	Flate = &Compression{
+		Suffix: ".deflate",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.DefaultCompression)
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return flate.NewReader(r), nil
+		},
+	}
+)
+
+// CompressedFS is a FileSystem compressing the files created and
+// decompressing the files opened in the underlying FileSystem. The
+// Compression of a file is selected by the suffix of its name among Gzip, Zlib
+// and Flate, or Default if no suffix matches.
+//
+// Stat returns the size of the decompressed bytes, which is computed by
+// decompressing the whole file, while the FileInfos returned by ReadDir have
+// the sizes of the compressed files.
+type CompressedFS struct {
+	FileSystem
+	// The Compression of files with no known suffix. If nil, they are stored
+	// uncompressed.
+	Default *Compression
+}
+
+var compressions = []*Compression{Gzip, Zlib, Flate}
+
+// compressionOf returns the Compression of a file, or nil if it is stored
+// uncompressed.
+func (fs CompressedFS) compressionOf(fn string) *Compression {
+	for _, c := range compressions {
+		if strings.HasSuffix(fn, c.Suffix) {
+			return c
+		}
+	}
+	return fs.Default
+}
+
+// FileSystem interface
+func (fs CompressedFS) Create(fn string) (WriteCloser, error) {
+	w, err := fs.FileSystem.Create(fn)
+	if err != nil {
+		return nil, err
+	}
+	c := fs.compressionOf(fn)
+	if c == nil {
+		return w, nil
+	}
+	zw, err := c.NewWriter(w)
+	if err != nil {
+		w.Close()
+		return nil, errorsp.WithStacks(err)
+	}
+	return &compressedWriter{Writer: bufio.NewWriter(zw), zw: zw, w: w}, nil
+}
+
+// FileSystem interface. The returned ReadCloser skips by decompressing.
+func (fs CompressedFS) Open(fn string) (ReadCloser, error) {
+	r, err := fs.FileSystem.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	c := fs.compressionOf(fn)
+	if c == nil {
+		return r, nil
+	}
+	zr, err := c.NewReader(r)
+	if err != nil {
+		r.Close()
+		return nil, errorsp.WithStacksAndMessage(err, "opening %s failed", fn)
+	}
+	return &decompressedReader{Reader: NewReader(zr), zr: zr, r: r}, nil
+}
+
+// FileSystem interface. The size of a file is of the decompressed bytes.
+func (fs CompressedFS) Stat(fn string) (os.FileInfo, error) {
+	fi, err := fs.FileSystem.Stat(fn)
+	if err != nil || fi.IsDir() || fs.compressionOf(fn) == nil {
+		return fi, err
+	}
+	r, err := fs.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	size, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return nil, errorsp.WithStacksAndMessage(err, "decompressing %s failed", fn)
+	}
+	return sizedFileInfo{FileInfo: fi, size: size}, nil
+}
+
+// compressedWriter writes to w through a buffer and a compressor.
+type compressedWriter struct {
+	*bufio.Writer
+	zw io.WriteCloser
+	w  WriteCloser
+}
+
+// io.Closer interface. It flushes the buffer and the compressor, and closes
+// the underlying file.
+func (cw *compressedWriter) Close() error {
+	err := cw.Flush()
+	if err2 := cw.zw.Close(); err == nil {
+		err = err2
+	}
+	if err2 := cw.w.Close(); err == nil {
+		err = err2
+	}
+	return errorsp.WithStacks(err)
+}
+
+// decompressedReader reads the decompressed bytes of r.
+type decompressedReader struct {
+	Reader
+	zr io.ReadCloser
+	r  ReadCloser
+}
+
+// io.Closer interface. It closes the decompressor and the underlying file.
+func (dr *decompressedReader) Close() error {
+	err := dr.zr.Close()
+	if err2 := dr.r.Close(); err == nil {
+		err = err2
+	}
+	return errorsp.WithStacks(err)
+}
+
+// sizedFileInfo is an os.FileInfo with the size replaced.
+type sizedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+// os.FileInfo interface
+func (fi sizedFileInfo) Size() int64 {
+	return fi.size
+}
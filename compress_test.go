@@ -0,0 +1,69 @@
+package sophie
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestCompressedFS(t *testing.T) {
+	mem := NewMemFS()
+	fs := CompressedFS{FileSystem: mem}
+	text := String(strings.Repeat("abcdefgh", 1000))
+	for _, fn := range []string{"/f.gz", "/f.zlib", "/f.deflate", "/f"} {
+		w, err := fs.Create(fn)
+		assert.NoErrorOrDie(t, err)
+		assert.NoError(t, text.WriteTo(w))
+		assert.NoError(t, VInt(123).WriteTo(w))
+		assert.NoError(t, w.Close())
+
+		fi, err := fs.Stat(fn)
+		assert.NoError(t, err)
+		assert.Equal(t, "Size of "+fn, fi.Size(), int64(EncodedLen(text)+1))
+		raw, err := mem.Stat(fn)
+		assert.NoError(t, err)
+		assert.Equal(t, "compressed "+fn, raw.Size() < fi.Size(), fn != "/f")
+
+		r, err := fs.Open(fn)
+		assert.NoErrorOrDie(t, err)
+		n, err := r.Skip(int64(EncodedLen(text)))
+		assert.NoError(t, err)
+		assert.Equal(t, "n", n, int64(EncodedLen(text)))
+		var v VInt
+		assert.NoError(t, v.ReadFrom(r, UNKNOWN_LEN))
+		assert.Equal(t, "v", v, VInt(123))
+		_, err = r.ReadByte()
+		assert.Equal(t, "err", err, io.EOF)
+		assert.NoError(t, r.Close())
+	}
+
+	// Default
+	fs.Default = Gzip
+	w, err := AtomicFS{fs}.Create("/g")
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, text.WriteTo(w))
+	assert.NoError(t, w.Close())
+	raw, err := mem.Stat("/g")
+	assert.NoError(t, err)
+	assert.True(t, "compressed", raw.Size() < int64(len(text)))
+	r, err := fs.Open("/g")
+	assert.NoErrorOrDie(t, err)
+	var s String
+	assert.NoError(t, s.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "s", s, text)
+	assert.NoError(t, r.Close())
+
+	// Not compressed
+	_, err = fs.Open("/f.gz.not")
+	assert.Error(t, err)
+	w, err = mem.Create("/bad.gz")
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, text.WriteTo(w))
+	assert.NoError(t, w.Close())
+	_, err = fs.Open("/bad.gz")
+	assert.Error(t, err)
+	_, err = fs.Stat("/bad.gz")
+	assert.Error(t, err)
+}
@@ -0,0 +1,264 @@
+package sophie
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+)
+
+// *Slice implements Sophier interface. It is serialized as a VInt of the
+// length followed by the elements. PT is always *T, which can be inferred in
+// NewSlice, e.g. NewSlice[VInt].
+//
+// When reading, the elements beyond the length are kept in the capacity and
+// reused by later calls to ReadFrom.
+type Slice[T any, PT interface {
+	*T
+	Sophier
+}] []T
+
+// Returns a new instance of *Slice as a Sophier
+func NewSlice[T any, PT interface {
+	*T
+	Sophier
+}]() Sophier {
+	return new(Slice[T, PT])
+}
+
+// SophieWriter interface
+func (s Slice[T, PT]) WriteTo(w Writer) error {
+	if err := VInt(len(s)).WriteTo(w); err != nil {
+		return err
+	}
+	for i := range s {
+		if err := PT(&s[i]).WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sizer interface
+func (s Slice[T, PT]) EncodedLen() int {
+	n := uvarintLen(uint64(len(s)))
+	for i := range s {
+		n += EncodedLen(PT(&s[i]))
+	}
+	return n
+}
+
+// SophieReader interface
+func (s *Slice[T, PT]) ReadFrom(r Reader, l int) error {
+	var n VInt
+	if err := n.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := DecodeLimits.CheckCount(int(n)); err != nil {
+		return err
+	}
+	*s = prealloc(*s, int(n))
+	for i := 0; i < int(n); i++ {
+		if i < cap(*s) {
+			// Reuses the element in the capacity
+			*s = (*s)[:i+1]
+		} else {
+			*s = append(*s, *new(T))
+		}
+		if err := PT(&(*s)[i]).ReadFrom(r, UNKNOWN_LEN); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// *Map implements Sophier interface. It is serialized as a VInt of the
+// number of entries followed by key-value pairs in the order of the
+// serialized bytes of the keys, so the same map always has the same
+// serialized bytes. PK and PV are always *K and *V, which can be inferred in
+// NewMap, e.g. NewMap[String, VInt].
+type Map[K comparable, V any, PK interface {
+	*K
+	Sophier
+}, PV interface {
+	*V
+	Sophier
+}] map[K]V
+
+// Returns a new instance of *Map as a Sophier
+func NewMap[K comparable, V any, PK interface {
+	*K
+	Sophier
+}, PV interface {
+	*V
+	Sophier
+}]() Sophier {
+	return new(Map[K, V, PK, PV])
+}
+
+type mapEntry[K comparable] struct {
+	encoded []byte
+	key     K
+}
+
+// SophieWriter interface
+func (m Map[K, V, PK, PV]) WriteTo(w Writer) error {
+	if err := VInt(len(m)).WriteTo(w); err != nil {
+		return err
+	}
+	var buf bytesp.Slice
+	entries := make([]mapEntry[K], 0, len(m))
+	for k := range m {
+		start := len(buf)
+		if err := PK(&k).WriteTo(&buf); err != nil {
+			return err
+		}
+		entries = append(entries, mapEntry[K]{encoded: buf[start:len(buf):len(buf)], key: k})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].encoded, entries[j].encoded) < 0
+	})
+	for _, e := range entries {
+		if _, err := w.Write(e.encoded); err != nil {
+			return errorsp.WithStacks(err)
+		}
+		v := m[e.key]
+		if err := PV(&v).WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sizer interface
+func (m Map[K, V, PK, PV]) EncodedLen() int {
+	n := uvarintLen(uint64(len(m)))
+	for k, v := range m {
+		n += EncodedLen(PK(&k)) + EncodedLen(PV(&v))
+	}
+	return n
+}
+
+// SophieReader interface
+func (m *Map[K, V, PK, PV]) ReadFrom(r Reader, l int) error {
+	var n VInt
+	if err := n.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := DecodeLimits.CheckCount(int(n)); err != nil {
+		return err
+	}
+	if *m == nil {
+		*m = make(Map[K, V, PK, PV], min(int(n), maxPrealloc))
+	} else {
+		clear(*m)
+	}
+	for i := 0; i < int(n); i++ {
+		var k K
+		if err := PK(&k).ReadFrom(r, UNKNOWN_LEN); err != nil {
+			return err
+		}
+		var v V
+		if err := PV(&v).ReadFrom(r, UNKNOWN_LEN); err != nil {
+			return err
+		}
+		(*m)[k] = v
+	}
+	return nil
+}
+
+// *Optional implements Sophier interface. It is serialized as a Bool of
+// Valid, followed by Val if Valid is true. PT is always *T, which can be
+// inferred in NewOptional, e.g. NewOptional[String].
+type Optional[T any, PT interface {
+	*T
+	Sophier
+}] struct {
+	Val   T
+	Valid bool
+}
+
+// Returns a new instance of *Optional as a Sophier
+func NewOptional[T any, PT interface {
+	*T
+	Sophier
+}]() Sophier {
+	return new(Optional[T, PT])
+}
+
+// SophieWriter interface
+func (o Optional[T, PT]) WriteTo(w Writer) error {
+	if err := Bool(o.Valid).WriteTo(w); err != nil {
+		return err
+	}
+	if !o.Valid {
+		return nil
+	}
+	return PT(&o.Val).WriteTo(w)
+}
+
+// Sizer interface
+func (o Optional[T, PT]) EncodedLen() int {
+	if !o.Valid {
+		return 1
+	}
+	return 1 + EncodedLen(PT(&o.Val))
+}
+
+// SophieReader interface
+func (o *Optional[T, PT]) ReadFrom(r Reader, l int) error {
+	if err := (*Bool)(&o.Valid).ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if !o.Valid {
+		return nil
+	}
+	return PT(&o.Val).ReadFrom(r, UNKNOWN_LEN)
+}
+
+// *Pair implements Sophier interface. It is serialized as First followed by
+// Second. PA and PB are always *A and *B, which can be inferred in NewPair,
+// e.g. NewPair[String, VInt].
+type Pair[A, B any, PA interface {
+	*A
+	Sophier
+}, PB interface {
+	*B
+	Sophier
+}] struct {
+	First  A
+	Second B
+}
+
+// Returns a new instance of *Pair as a Sophier
+func NewPair[A, B any, PA interface {
+	*A
+	Sophier
+}, PB interface {
+	*B
+	Sophier
+}]() Sophier {
+	return new(Pair[A, B, PA, PB])
+}
+
+// SophieWriter interface
+func (p Pair[A, B, PA, PB]) WriteTo(w Writer) error {
+	if err := PA(&p.First).WriteTo(w); err != nil {
+		return err
+	}
+	return PB(&p.Second).WriteTo(w)
+}
+
+// Sizer interface
+func (p Pair[A, B, PA, PB]) EncodedLen() int {
+	return EncodedLen(PA(&p.First)) + EncodedLen(PB(&p.Second))
+}
+
+// SophieReader interface
+func (p *Pair[A, B, PA, PB]) ReadFrom(r Reader, l int) error {
+	if err := PA(&p.First).ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	return PB(&p.Second).ReadFrom(r, UNKNOWN_LEN)
+}
@@ -0,0 +1,105 @@
+package sophie
+
+import (
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestSlice(t *testing.T) {
+	var sa, sb Slice[VInt, *VInt]
+	readWrite(t, &sa, &sb, 1)
+	assert.Equal(t, "len(sb)", len(sb), 0)
+
+	sa = Slice[VInt, *VInt]{1, 200, 3}
+	readWrite(t, &sa, &sb, 5)
+	assert.Equal(t, "sb", sb, sa)
+
+	// Capacity and elements are reused
+	strs := Slice[ByteSlice, *ByteSlice]{ByteSlice("abc"), ByteSlice("def")}
+	var strsB Slice[ByteSlice, *ByteSlice]
+	readWrite(t, &strs, &strsB, -1)
+	assert.Equal(t, "strsB", strsB, strs)
+	first := &strsB[:2][1]
+	strs = strs[:1]
+	readWrite(t, &strs, &strsB, -1)
+	assert.Equal(t, "strsB", strsB, strs)
+	assert.Equal(t, "&strsB[:2][1]", &strsB[:2][1], first)
+
+	s := NewSlice[String]()
+	_, ok := s.(*Slice[String, *String])
+	assert.True(t, "ok", ok)
+}
+
+func TestSlice_Compatible(t *testing.T) {
+	// Slice of String is compatible with WriteStringSlice.
+	var buf bytesp.Slice
+	assert.NoError(t, Slice[String, *String]{"a", "bc"}.WriteTo(&buf))
+	var sl []string
+	assert.NoError(t, ReadStringSlice(&buf, &sl))
+	assert.Equal(t, "sl", sl, []string{"a", "bc"})
+}
+
+func TestMap(t *testing.T) {
+	ma := Map[String, VInt, *String, *VInt]{"b": 2, "a": 1, "c": 300}
+	var mb Map[String, VInt, *String, *VInt]
+	readWrite(t, &ma, &mb, 3*2+4+1)
+	assert.Equal(t, "mb", mb, ma)
+
+	// Deterministic order
+	var buf bytesp.Slice
+	assert.NoError(t, ma.WriteTo(&buf))
+	assert.Equal(t, "buf", buf, bytesp.Slice("\x03\x01a\x01\x01b\x02\x01c\xac\x02"))
+
+	// Entries are cleared
+	ma = Map[String, VInt, *String, *VInt]{"d": 4}
+	readWrite(t, &ma, &mb, -1)
+	assert.Equal(t, "mb", mb, ma)
+
+	m := NewMap[VInt, String]()
+	_, ok := m.(*Map[VInt, String, *VInt, *String])
+	assert.True(t, "ok", ok)
+}
+
+func TestOptional(t *testing.T) {
+	var oa, ob Optional[String, *String]
+	readWrite(t, &oa, &ob, 1)
+	assert.Equal(t, "ob", ob, oa)
+
+	oa = Optional[String, *String]{Val: "abc", Valid: true}
+	readWrite(t, &oa, &ob, 5)
+	assert.Equal(t, "ob", ob, oa)
+
+	oa = Optional[String, *String]{}
+	readWrite(t, &oa, &ob, 1)
+	assert.False(t, "ob.Valid", ob.Valid)
+
+	_, ok := NewOptional[VInt]().(*Optional[VInt, *VInt])
+	assert.True(t, "ok", ok)
+}
+
+func TestPair(t *testing.T) {
+	pa := Pair[String, Int32, *String, *Int32]{First: "a", Second: 10}
+	var pb Pair[String, Int32, *String, *Int32]
+	readWrite(t, &pa, &pb, 6)
+	assert.Equal(t, "pb", pb, pa)
+
+	_, ok := NewPair[String, VInt]().(*Pair[String, VInt, *String, *VInt])
+	assert.True(t, "ok", ok)
+}
+
+func TestContainers_BadFormat(t *testing.T) {
+	var s Slice[VInt, *VInt]
+	bs := bytesp.Slice("\x03\x01")
+	assert.Error(t, s.ReadFrom(&bs, -1))
+
+	var m Map[VInt, VInt, *VInt, *VInt]
+	bs = bytesp.Slice("\x02\x01\x02\x01")
+	assert.Error(t, m.ReadFrom(&bs, -1))
+
+	var o Optional[VInt, *VInt]
+	bs = bytesp.Slice("\x05")
+	assert.Equal(t, "o.ReadFrom", errorsp.Cause(o.ReadFrom(&bs, -1)), ErrBadFormat)
+}
@@ -0,0 +1,374 @@
+package sophie
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/golangplus/errors"
+)
+
+// readFixed reads exactly len(buf) bytes to buf. ErrBadFormat is returned if
+// l is known but not equal to len(buf).
+func readFixed(r Reader, l int, buf []byte) error {
+	if l != UNKNOWN_LEN && l != len(buf) {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "l = %d", l)
+	}
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return nil
+}
+
+// *Int8 implements Sophie interface
+type Int8 int8
+
+// Returns a new instance of *Int8 as a Sophier
+func NewInt8() Sophier {
+	return new(Int8)
+}
+
+// SophieWriter interface
+func (i Int8) WriteTo(w Writer) error {
+	return errorsp.WithStacks(w.WriteByte(byte(i)))
+}
+
+// Sizer interface
+func (Int8) EncodedLen() int {
+	return 1
+}
+
+// SophieReader interface
+func (i *Int8) ReadFrom(r Reader, l int) error {
+	if l != UNKNOWN_LEN && l != 1 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "l = %d", l)
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	*i = Int8(b)
+	return nil
+}
+
+func (i *Int8) Val() int8 {
+	return int8(*i)
+}
+
+// *Int16 implements Sophie interface
+type Int16 int16
+
+// Returns a new instance of *Int16 as a Sophier
+func NewInt16() Sophier {
+	return new(Int16)
+}
+
+// SophieWriter interface
+func (i Int16) WriteTo(w Writer) error {
+	var arr [2]byte
+	binary.LittleEndian.PutUint16(arr[:], uint16(i))
+	_, err := w.Write(arr[:])
+	return errorsp.WithStacks(err)
+}
+
+// Sizer interface
+func (Int16) EncodedLen() int {
+	return 2
+}
+
+// SophieReader interface
+func (i *Int16) ReadFrom(r Reader, l int) error {
+	var arr [2]byte
+	if err := readFixed(r, l, arr[:]); err != nil {
+		return err
+	}
+	*i = Int16(binary.LittleEndian.Uint16(arr[:]))
+	return nil
+}
+
+func (i *Int16) Val() int16 {
+	return int16(*i)
+}
+
+// *Int64 implements Sophie interface
+type Int64 int64
+
+// Returns a new instance of *Int64 as a Sophier
+func NewInt64() Sophier {
+	return new(Int64)
+}
+
+// SophieWriter interface
+func (i Int64) WriteTo(w Writer) error {
+	var arr [8]byte
+	binary.LittleEndian.PutUint64(arr[:], uint64(i))
+	_, err := w.Write(arr[:])
+	return errorsp.WithStacks(err)
+}
+
+// Sizer interface
+func (Int64) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (i *Int64) ReadFrom(r Reader, l int) error {
+	var arr [8]byte
+	if err := readFixed(r, l, arr[:]); err != nil {
+		return err
+	}
+	*i = Int64(binary.LittleEndian.Uint64(arr[:]))
+	return nil
+}
+
+func (i *Int64) Val() int64 {
+	return int64(*i)
+}
+
+// *Uint8 implements Sophie interface
+type Uint8 uint8
+
+// Returns a new instance of *Uint8 as a Sophier
+func NewUint8() Sophier {
+	return new(Uint8)
+}
+
+// SophieWriter interface
+func (i Uint8) WriteTo(w Writer) error {
+	return errorsp.WithStacks(w.WriteByte(byte(i)))
+}
+
+// Sizer interface
+func (Uint8) EncodedLen() int {
+	return 1
+}
+
+// SophieReader interface
+func (i *Uint8) ReadFrom(r Reader, l int) error {
+	if l != UNKNOWN_LEN && l != 1 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "l = %d", l)
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	*i = Uint8(b)
+	return nil
+}
+
+func (i *Uint8) Val() uint8 {
+	return uint8(*i)
+}
+
+// *Uint16 implements Sophie interface
+type Uint16 uint16
+
+// Returns a new instance of *Uint16 as a Sophier
+func NewUint16() Sophier {
+	return new(Uint16)
+}
+
+// SophieWriter interface
+func (i Uint16) WriteTo(w Writer) error {
+	var arr [2]byte
+	binary.LittleEndian.PutUint16(arr[:], uint16(i))
+	_, err := w.Write(arr[:])
+	return errorsp.WithStacks(err)
+}
+
+// Sizer interface
+func (Uint16) EncodedLen() int {
+	return 2
+}
+
+// SophieReader interface
+func (i *Uint16) ReadFrom(r Reader, l int) error {
+	var arr [2]byte
+	if err := readFixed(r, l, arr[:]); err != nil {
+		return err
+	}
+	*i = Uint16(binary.LittleEndian.Uint16(arr[:]))
+	return nil
+}
+
+func (i *Uint16) Val() uint16 {
+	return uint16(*i)
+}
+
+// *Uint32 implements Sophie interface
+type Uint32 uint32
+
+// Returns a new instance of *Uint32 as a Sophier
+func NewUint32() Sophier {
+	return new(Uint32)
+}
+
+// SophieWriter interface
+func (i Uint32) WriteTo(w Writer) error {
+	var arr [4]byte
+	binary.LittleEndian.PutUint32(arr[:], uint32(i))
+	_, err := w.Write(arr[:])
+	return errorsp.WithStacks(err)
+}
+
+// Sizer interface
+func (Uint32) EncodedLen() int {
+	return 4
+}
+
+// SophieReader interface
+func (i *Uint32) ReadFrom(r Reader, l int) error {
+	var arr [4]byte
+	if err := readFixed(r, l, arr[:]); err != nil {
+		return err
+	}
+	*i = Uint32(binary.LittleEndian.Uint32(arr[:]))
+	return nil
+}
+
+func (i *Uint32) Val() uint32 {
+	return uint32(*i)
+}
+
+// *Uint64 implements Sophie interface
+type Uint64 uint64
+
+// Returns a new instance of *Uint64 as a Sophier
+func NewUint64() Sophier {
+	return new(Uint64)
+}
+
+// SophieWriter interface
+func (i Uint64) WriteTo(w Writer) error {
+	var arr [8]byte
+	binary.LittleEndian.PutUint64(arr[:], uint64(i))
+	_, err := w.Write(arr[:])
+	return errorsp.WithStacks(err)
+}
+
+// Sizer interface
+func (Uint64) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (i *Uint64) ReadFrom(r Reader, l int) error {
+	var arr [8]byte
+	if err := readFixed(r, l, arr[:]); err != nil {
+		return err
+	}
+	*i = Uint64(binary.LittleEndian.Uint64(arr[:]))
+	return nil
+}
+
+func (i *Uint64) Val() uint64 {
+	return uint64(*i)
+}
+
+// *Float32 implements Sophie interface. It is serialized as the IEEE 754
+// binary representation in little-endian.
+type Float32 float32
+
+// Returns a new instance of *Float32 as a Sophier
+func NewFloat32() Sophier {
+	return new(Float32)
+}
+
+// SophieWriter interface
+func (f Float32) WriteTo(w Writer) error {
+	return Uint32(math.Float32bits(float32(f))).WriteTo(w)
+}
+
+// Sizer interface
+func (Float32) EncodedLen() int {
+	return 4
+}
+
+// SophieReader interface
+func (f *Float32) ReadFrom(r Reader, l int) error {
+	var bits Uint32
+	if err := bits.ReadFrom(r, l); err != nil {
+		return err
+	}
+	*f = Float32(math.Float32frombits(uint32(bits)))
+	return nil
+}
+
+func (f *Float32) Val() float32 {
+	return float32(*f)
+}
+
+// *Float64 implements Sophie interface. It is serialized as the IEEE 754
+// binary representation in little-endian.
+type Float64 float64
+
+// Returns a new instance of *Float64 as a Sophier
+func NewFloat64() Sophier {
+	return new(Float64)
+}
+
+// SophieWriter interface
+func (f Float64) WriteTo(w Writer) error {
+	return Uint64(math.Float64bits(float64(f))).WriteTo(w)
+}
+
+// Sizer interface
+func (Float64) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (f *Float64) ReadFrom(r Reader, l int) error {
+	var bits Uint64
+	if err := bits.ReadFrom(r, l); err != nil {
+		return err
+	}
+	*f = Float64(math.Float64frombits(uint64(bits)))
+	return nil
+}
+
+func (f *Float64) Val() float64 {
+	return float64(*f)
+}
+
+// *Bool implements Sophie interface. It is serialized as a single byte of 0 or
+// 1, other values are considered as bad format.
+type Bool bool
+
+// Returns a new instance of *Bool as a Sophier
+func NewBool() Sophier {
+	return new(Bool)
+}
+
+// SophieWriter interface
+func (b Bool) WriteTo(w Writer) error {
+	if b {
+		return errorsp.WithStacks(w.WriteByte(1))
+	}
+	return errorsp.WithStacks(w.WriteByte(0))
+}
+
+// Sizer interface
+func (Bool) EncodedLen() int {
+	return 1
+}
+
+// SophieReader interface
+func (b *Bool) ReadFrom(r Reader, l int) error {
+	if l != UNKNOWN_LEN && l != 1 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "l = %d", l)
+	}
+	v, err := r.ReadByte()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	if v > 1 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "invalid bool byte %d", v)
+	}
+	*b = v == 1
+	return nil
+}
+
+func (b *Bool) Val() bool {
+	return bool(*b)
+}
@@ -0,0 +1,99 @@
+package sophie
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestFixedInts(t *testing.T) {
+	var i8a, i8b Int8
+	i8a = -12
+	readWrite(t, &i8a, &i8b, 1)
+	assert.Equal(t, "i8b", i8b, i8a)
+
+	var i16a, i16b Int16
+	i16a = -1234
+	readWrite(t, &i16a, &i16b, 2)
+	assert.Equal(t, "i16b", i16b, i16a)
+
+	var i64a, i64b Int64
+	i64a = math.MinInt64
+	readWrite(t, &i64a, &i64b, 8)
+	assert.Equal(t, "i64b", i64b, i64a)
+
+	var u8a, u8b Uint8
+	u8a = 255
+	readWrite(t, &u8a, &u8b, 1)
+	assert.Equal(t, "u8b", u8b, u8a)
+
+	var u16a, u16b Uint16
+	u16a = 65535
+	readWrite(t, &u16a, &u16b, 2)
+	assert.Equal(t, "u16b", u16b, u16a)
+
+	var u32a, u32b Uint32
+	u32a = 0xdeadbeef
+	readWrite(t, &u32a, &u32b, 4)
+	assert.Equal(t, "u32b", u32b, u32a)
+
+	var u64a, u64b Uint64
+	u64a = math.MaxUint64
+	readWrite(t, &u64a, &u64b, 8)
+	assert.Equal(t, "u64b", u64b, u64a)
+}
+
+func TestFixedInts_Endian(t *testing.T) {
+	var buf bytesp.Slice
+	assert.NoError(t, Int64(0x0102030405060708).WriteTo(&buf))
+	assert.Equal(t, "buf", buf, bytesp.Slice("\x08\x07\x06\x05\x04\x03\x02\x01"))
+	// Same layout as Int32
+	buf = nil
+	assert.NoError(t, Uint32(0x01020304).WriteTo(&buf))
+	var i32 Int32
+	assert.NoError(t, i32.ReadFrom(&buf, 4))
+	assert.Equal(t, "i32", i32, Int32(0x01020304))
+}
+
+func TestFloats(t *testing.T) {
+	var f32a, f32b Float32
+	f32a = -3.25
+	readWrite(t, &f32a, &f32b, 4)
+	assert.Equal(t, "f32b", f32b, f32a)
+
+	var f64a, f64b Float64
+	f64a = math.Pi
+	readWrite(t, &f64a, &f64b, 8)
+	assert.Equal(t, "f64b", f64b, f64a)
+	f64a = Float64(math.Inf(-1))
+	readWrite(t, &f64a, &f64b, 8)
+	assert.Equal(t, "f64b", f64b, f64a)
+}
+
+func TestBool(t *testing.T) {
+	var ba, bb Bool
+	ba = true
+	readWrite(t, &ba, &bb, 1)
+	assert.Equal(t, "bb", bb, ba)
+	ba = false
+	readWrite(t, &ba, &bb, 1)
+	assert.Equal(t, "bb", bb, ba)
+
+	bs := bytesp.Slice("\x02")
+	assert.Equal(t, "bb.ReadFrom(&bs, 1)", errorsp.Cause(bb.ReadFrom(&bs, 1)), ErrBadFormat)
+}
+
+func TestFixed_WrongLength(t *testing.T) {
+	bs := bytesp.Slice("12345678")
+	var i64 Int64
+	assert.Equal(t, "i64.ReadFrom(&bs, 4)", errorsp.Cause(i64.ReadFrom(&bs, 4)), ErrBadFormat)
+	var u16 Uint16
+	assert.Equal(t, "u16.ReadFrom(&bs, 1)", errorsp.Cause(u16.ReadFrom(&bs, 1)), ErrBadFormat)
+	var i8 Int8
+	assert.Equal(t, "i8.ReadFrom(&bs, 2)", errorsp.Cause(i8.ReadFrom(&bs, 2)), ErrBadFormat)
+	var f32 Float32
+	assert.Equal(t, "f32.ReadFrom(&bs, 8)", errorsp.Cause(f32.ReadFrom(&bs, 8)), ErrBadFormat)
+}
@@ -2,6 +2,7 @@ package sophie
 
 import (
 	"bufio"
+	"io"
 	"os"
 
 	"github.com/daviddengcn/go-villa"
@@ -14,7 +15,9 @@ type FileSystem interface {
 	// Mkdir makes the directory and its parents if necessary of specifiy path
 	// and perm.
 	Mkdir(path string, perm os.FileMode) error
-	// Open opens a ReadCloser for reading the file of a specified name.
+	// Open opens a ReadCloser for reading the file of a specified name. The
+	// ReadCloser may also implement io.Seeker and io.ReaderAt for random
+	// access, as those of LocalFS and MemFS do.
 	Open(fn string) (ReadCloser, error)
 	// ReadDir reads the FileInfos of the files under a specified directory.
 	ReadDir(dir string) ([]os.FileInfo, error)
@@ -22,6 +25,9 @@ type FileSystem interface {
 	Stat(fn string) (os.FileInfo, error)
 	// Remove deletes a file or a directory(and all its file/directories in it)
 	Remove(fn string) error
+	// Rename renames a file or a directory. An existing file at newpath is
+	// replaced.
+	Rename(oldpath, newpath string) error
 }
 
 // BufferedFileWriter is a sophie.WriteCloser with buffer.
@@ -30,6 +36,14 @@ type BufferedFileWriter struct {
 	*bufio.Writer
 }
 
+// Sync flushes the buffer and commits the file to stable storage.
+func (b BufferedFileWriter) Sync() error {
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return b.file.Sync()
+}
+
 // sophie.WriteCloser interface
 func (b BufferedFileWriter) Close() error {
 	if err := b.Flush(); err != nil {
@@ -38,7 +52,8 @@ func (b BufferedFileWriter) Close() error {
 	return b.file.Close()
 }
 
-// BufferedFileReader is a sophie.ReadCloser with buffer
+// BufferedFileReader is a sophie.ReadCloser with buffer. It also implements
+// io.Seeker and io.ReaderAt.
 type BufferedFileReader struct {
 	file *os.File
 	*bufio.Reader
@@ -49,21 +64,28 @@ func (b BufferedFileReader) Close() error {
 	return b.file.Close()
 }
 
-// sophie.Reader interface
+// sophie.Reader interface. Bytes not in the buffer are skipped by seeking.
 func (b BufferedFileReader) Skip(n int64) (int64, error) {
-	var arr [1024]byte
-	left := n
-	for left > 0 {
-		l := left
-		if l > int64(len(arr)) {
-			l = int64(len(arr))
-		}
-		if _, err := b.Read(arr[:l]); err != nil {
-			return n - left, err
-		}
-		left -= l
+	return skipBuffered(b.Reader, b.file, b.file, n)
+}
+
+// io.Seeker interface. The position is of the bytes read from the reader, not
+// of the underlying file which is read ahead. The buffer is reset.
+func (b BufferedFileReader) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekCurrent {
+		offset -= int64(b.Buffered())
+	}
+	pos, err := b.file.Seek(offset, whence)
+	if err != nil {
+		return pos, err
 	}
-	return n, nil
+	b.Reader.Reset(b.file)
+	return pos, nil
+}
+
+// io.ReaderAt interface. It does not change the position.
+func (b BufferedFileReader) ReadAt(p []byte, off int64) (int, error) {
+	return b.file.ReadAt(p, off)
 }
 
 type localFileSystem struct{}
@@ -118,6 +140,11 @@ func (lfs localFileSystem) Remove(fn string) error {
 	return villa.Path(fn).RemoveAll()
 }
 
+// FileSystem interface
+func (lfs localFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
 // FsPath is a pair of FileSystem and a path
 type FsPath struct {
 	// The FileSystem
@@ -144,6 +171,11 @@ func (fp FsPath) Create() (WriteCloser, error) {
 	return fp.Fs.Create(fp.Path)
 }
 
+// Calls CreateAtomic with the FileSystem and the path
+func (fp FsPath) CreateAtomic() (WriteCloser, error) {
+	return CreateAtomic(fp.Fs, fp.Path)
+}
+
 // Calls FileSystem.Open with the path
 func (fp FsPath) Open() (ReadCloser, error) {
 	return fp.Fs.Open(fp.Path)
@@ -169,6 +201,11 @@ func (fp FsPath) Remove() error {
 	return fp.Fs.Remove(fp.Path)
 }
 
+// Calls FileSystem.Rename with the path and newpath
+func (fp FsPath) Rename(newpath string) error {
+	return fp.Fs.Rename(fp.Path, newpath)
+}
+
 // Join returns a new FsPath with the same FileSystem and the path joined with
 // sub
 func (fp FsPath) Join(sub string) FsPath {
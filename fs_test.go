@@ -0,0 +1,86 @@
+package sophie
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestBufferedFileReader_Seek(t *testing.T) {
+	for _, fp := range []FsPath{
+		TempDirPath().Join("TestBufferedFileReader_Seek"),
+		{Fs: NewMemFS(), Path: "/TestBufferedFileReader_Seek"},
+	} {
+		src := strings.Repeat("0123456789", 2000)
+		w, err := fp.Create()
+		assert.NoErrorOrDie(t, err)
+		_, err = w.Write([]byte(src))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		r, err := fp.Open()
+		assert.NoErrorOrDie(t, err)
+		b, err := r.ReadByte()
+		assert.NoError(t, err)
+		assert.Equal(t, "b", b, byte('0'))
+
+		seeker, ok := r.(io.Seeker)
+		assert.True(t, "seeker", ok)
+		pos, err := seeker.Seek(0, io.SeekCurrent)
+		assert.NoError(t, err)
+		assert.Equal(t, "pos", pos, int64(1))
+
+		// Beyond the buffer
+		n, err := r.Skip(10002)
+		assert.NoError(t, err)
+		assert.Equal(t, "n", n, int64(10002))
+		b, err = r.ReadByte()
+		assert.NoError(t, err)
+		assert.Equal(t, "b", b, byte('3'))
+		pos, err = seeker.Seek(0, io.SeekCurrent)
+		assert.NoError(t, err)
+		assert.Equal(t, "pos", pos, int64(10004))
+
+		pos, err = seeker.Seek(5, io.SeekStart)
+		assert.NoError(t, err)
+		assert.Equal(t, "pos", pos, int64(5))
+		b, err = r.ReadByte()
+		assert.NoError(t, err)
+		assert.Equal(t, "b", b, byte('5'))
+
+		var arr [3]byte
+		_, err = r.(io.ReaderAt).ReadAt(arr[:], 17)
+		assert.NoError(t, err)
+		assert.Equal(t, "arr", string(arr[:]), "789")
+		b, err = r.ReadByte()
+		assert.NoError(t, err)
+		assert.Equal(t, "b", b, byte('6'))
+
+		// Beyond the end
+		n, err = r.Skip(30000)
+		assert.Equal(t, "err", errorsp.Cause(err), io.EOF)
+		assert.Equal(t, "n", n, int64(20000-7))
+
+		assert.NoError(t, r.Close())
+		assert.NoError(t, fp.Remove())
+	}
+}
+
+func TestBufferedFileWriter_Sync(t *testing.T) {
+	fp := TempDirPath().Join("TestBufferedFileWriter_Sync")
+	defer fp.Remove()
+
+	w, err := fp.Create()
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, String("abc").WriteTo(w))
+	assert.NoError(t, w.(BufferedFileWriter).Sync())
+	// The buffered bytes are flushed.
+	fi, err := os.Stat(fp.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "Size", fi.Size(), int64(4))
+	assert.NoError(t, w.Close())
+}
@@ -0,0 +1,349 @@
+package sophie
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/golangplus/errors"
+)
+
+// readListCount reads the VInt count of a list and checks it against
+// DecodeLimits and l. minBytes is the minimum number of bytes each element
+// takes.
+func readListCount(r Reader, l int, minBytes int) (int, error) {
+	var n VInt
+	if err := n.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return 0, err
+	}
+	if err := DecodeLimits.CheckCount(int(n)); err != nil {
+		return 0, err
+	}
+	if l != UNKNOWN_LEN && int(n)*minBytes > l {
+		return 0, errorsp.WithStacksAndMessage(ErrBadFormat, "%d elements cannot fit in %d bytes", n, l)
+	}
+	return int(n), nil
+}
+
+// *DeltaVIntSlice implements Sophier interface for a slice of ints in
+// ascending order, e.g. a posting list. It is serialized as a VInt of the
+// length, an SVInt of the first element and vints of the differences between
+// adjacent elements. WriteTo returns an error if the elements are not sorted.
+type DeltaVIntSlice []int
+
+// Returns a new instance of *DeltaVIntSlice as a Sophier
+func NewDeltaVIntSlice() Sophier {
+	return new(DeltaVIntSlice)
+}
+
+// SophieWriter interface
+func (s DeltaVIntSlice) WriteTo(w Writer) error {
+	if err := VInt(len(s)).WriteTo(w); err != nil {
+		return err
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	if err := SVInt(s[0]).WriteTo(w); err != nil {
+		return err
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] < s[i-1] {
+			return errorsp.NewWithStacks("DeltaVIntSlice is not sorted at %d: %d < %d", i, s[i], s[i-1])
+		}
+		if err := writeUVarint(w, uint64(s[i])-uint64(s[i-1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sizer interface
+func (s DeltaVIntSlice) EncodedLen() int {
+	n := uvarintLen(uint64(len(s)))
+	if len(s) == 0 {
+		return n
+	}
+	n += SVInt(s[0]).EncodedLen()
+	for i := 1; i < len(s); i++ {
+		n += uvarintLen(uint64(s[i]) - uint64(s[i-1]))
+	}
+	return n
+}
+
+// SophieReader interface
+func (s *DeltaVIntSlice) ReadFrom(r Reader, l int) error {
+	n, err := readListCount(r, l, 1)
+	if err != nil {
+		return err
+	}
+	*s = prealloc(*s, n)
+	if n == 0 {
+		return nil
+	}
+	var first SVInt
+	if err := first.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	*s = append(*s, int(first))
+	for i := 1; i < n; i++ {
+		d, err := readUVarint(r)
+		if err != nil {
+			return err
+		}
+		v := int(uint64((*s)[i-1]) + d)
+		if v < (*s)[i-1] {
+			return errorsp.WithStacksAndMessage(ErrBadFormat, "delta %d overflows", d)
+		}
+		*s = append(*s, v)
+	}
+	return nil
+}
+
+// *PackedIntSlice implements Sophier interface for a slice of ints with a
+// frame-of-reference bit-packed encoding, which is compact when the elements
+// are in a small range. It is serialized as a VInt of the length, followed by
+// (if not empty) an SVInt of the minimum element, a byte of the bit width of
+// the differences between the elements and the minimum, and the differences
+// packed in that width, lowest bits first. The width is 0, i.e. no bits, only
+// for a few equal elements, so a count cannot expand beyond the bytes read.
+type PackedIntSlice []int
+
+// Returns a new instance of *PackedIntSlice as a Sophier
+func NewPackedIntSlice() Sophier {
+	return new(PackedIntSlice)
+}
+
+// packedHeaderLen returns the length of the header of a PackedIntSlice of n
+// elements with the minimum lo, i.e. the bytes before the packed bits.
+func packedHeaderLen(n, lo int) int {
+	return uvarintLen(uint64(n)) + SVInt(lo).EncodedLen() + 1
+}
+
+// packedWidth returns the bit width of a PackedIntSlice of n elements in
+// [lo, hi]. A width of 0 is only used if n does not exceed the length of the
+// header, so that the elements decoded are bounded by the bytes read.
+func packedWidth(n, lo, hi int) uint {
+	width := uint(bits.Len64(uint64(hi) - uint64(lo)))
+	if width == 0 && n > packedHeaderLen(n, lo) {
+		width = 1
+	}
+	return width
+}
+
+// SophieWriter interface
+func (s PackedIntSlice) WriteTo(w Writer) error {
+	if err := VInt(len(s)).WriteTo(w); err != nil {
+		return err
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	lo, hi := s[0], s[0]
+	for _, v := range s[1:] {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	width := packedWidth(len(s), lo, hi)
+	if err := SVInt(lo).WriteTo(w); err != nil {
+		return err
+	}
+	if err := w.WriteByte(byte(width)); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	var cur byte
+	var nCur uint
+	for _, v := range s {
+		d := uint64(v) - uint64(lo)
+		for rem := width; rem > 0; {
+			take := 8 - nCur
+			if take > rem {
+				take = rem
+			}
+			cur |= byte(d&(1<<take-1)) << nCur
+			d >>= take
+			rem -= take
+			if nCur += take; nCur == 8 {
+				if err := w.WriteByte(cur); err != nil {
+					return errorsp.WithStacks(err)
+				}
+				cur, nCur = 0, 0
+			}
+		}
+	}
+	if nCur > 0 {
+		return errorsp.WithStacks(w.WriteByte(cur))
+	}
+	return nil
+}
+
+// Sizer interface
+func (s PackedIntSlice) EncodedLen() int {
+	if len(s) == 0 {
+		return uvarintLen(0)
+	}
+	lo, hi := s[0], s[0]
+	for _, v := range s[1:] {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	width := int(packedWidth(len(s), lo, hi))
+	return packedHeaderLen(len(s), lo) + (len(s)*width+7)/8
+}
+
+// SophieReader interface
+func (s *PackedIntSlice) ReadFrom(r Reader, l int) error {
+	n, err := readListCount(r, l, 0)
+	if err != nil {
+		return err
+	}
+	*s = prealloc(*s, n)
+	if n == 0 {
+		return nil
+	}
+	var lo SVInt
+	if err := lo.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	width := uint(b)
+	if width > 64 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "bit width %d", width)
+	}
+	if width == 0 && n > packedHeaderLen(n, int(lo)) {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "%d elements of 0 bits", n)
+	}
+	if l != UNKNOWN_LEN && (n*int(width)+7)/8 > l {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "%d elements of %d bits cannot fit in %d bytes", n, width, l)
+	}
+	var cur byte
+	var nCur uint
+	for i := 0; i < n; i++ {
+		var d uint64
+		for got := uint(0); got < width; {
+			if nCur == 0 {
+				if cur, err = r.ReadByte(); err != nil {
+					if errorsp.Cause(err) == io.EOF {
+						return errorsp.WithStacks(io.ErrUnexpectedEOF)
+					}
+					return errorsp.WithStacks(err)
+				}
+				nCur = 8
+			}
+			take := nCur
+			if take > width-got {
+				take = width - got
+			}
+			d |= uint64(cur&(1<<take-1)) << got
+			cur >>= take
+			nCur -= take
+			got += take
+		}
+		*s = append(*s, int(uint64(lo)+d))
+	}
+	return nil
+}
+
+// *Bitset implements Sophier interface for a set of non-negative ints. Bit i
+// of word i/64 is set if i is in the set. It is serialized as a VInt of the
+// number of words, trailing zero words excluded, followed by the words, each
+// in 8 bytes in little-endian.
+type Bitset []uint64
+
+// Returns a new instance of *Bitset as a Sophier
+func NewBitset() Sophier {
+	return new(Bitset)
+}
+
+// Set adds i to the set. A negative i is ignored.
+func (b *Bitset) Set(i int) {
+	if i < 0 {
+		return
+	}
+	w := i / 64
+	if n := len(*b); w >= n {
+		if w < cap(*b) {
+			*b = (*b)[:w+1]
+			clear((*b)[n:])
+		} else {
+			*b = append(*b, make([]uint64, w+1-len(*b))...)
+		}
+	}
+	(*b)[w] |= 1 << uint(i%64)
+}
+
+// Clear removes i from the set. A negative i is ignored.
+func (b Bitset) Clear(i int) {
+	if w := i / 64; i >= 0 && w < len(b) {
+		b[w] &^= 1 << uint(i%64)
+	}
+}
+
+// Has returns whether i is in the set. It is false for a negative i.
+func (b Bitset) Has(i int) bool {
+	w := i / 64
+	return i >= 0 && w < len(b) && b[w]&(1<<uint(i%64)) != 0
+}
+
+// Count returns the number of elements in the set.
+func (b Bitset) Count() int {
+	cnt := 0
+	for _, w := range b {
+		cnt += bits.OnesCount64(w)
+	}
+	return cnt
+}
+
+// SophieWriter interface
+func (b Bitset) WriteTo(w Writer) error {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	if err := VInt(len(b)).WriteTo(w); err != nil {
+		return err
+	}
+	var arr [8]byte
+	for _, word := range b {
+		binary.LittleEndian.PutUint64(arr[:], word)
+		if _, err := w.Write(arr[:]); err != nil {
+			return errorsp.WithStacks(err)
+		}
+	}
+	return nil
+}
+
+// Sizer interface
+func (b Bitset) EncodedLen() int {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return uvarintLen(uint64(len(b))) + len(b)*8
+}
+
+// SophieReader interface
+func (b *Bitset) ReadFrom(r Reader, l int) error {
+	n, err := readListCount(r, l, 8)
+	if err != nil {
+		return err
+	}
+	*b = prealloc(*b, n)
+	var arr [8]byte
+	for i := 0; i < n; i++ {
+		if err := readFixed(r, UNKNOWN_LEN, arr[:]); err != nil {
+			return err
+		}
+		*b = append(*b, binary.LittleEndian.Uint64(arr[:]))
+	}
+	return nil
+}
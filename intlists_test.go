@@ -0,0 +1,107 @@
+package sophie
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestDeltaVIntSlice(t *testing.T) {
+	var sa, sb DeltaVIntSlice
+	readWrite(t, &sa, &sb, 1)
+	assert.Equal(t, "len(sb)", len(sb), 0)
+
+	sa = DeltaVIntSlice{1000, 1001, 1001, 1100}
+	readWrite(t, &sa, &sb, 1+2+1+1+1)
+	assert.Equal(t, "sb", sb, sa)
+
+	sa = DeltaVIntSlice{-5, 0, math.MaxInt64}
+	readWrite(t, &sa, &sb, -1)
+	assert.Equal(t, "sb", sb, sa)
+
+	var buf bytesp.Slice
+	assert.Error(t, DeltaVIntSlice{2, 1}.WriteTo(&buf))
+
+	// The count exceeds the known length.
+	buf = bytesp.Slice("\x05\x00\x01")
+	assert.Equal(t, "ReadFrom", errorsp.Cause(sb.ReadFrom(&buf, len(buf))), ErrBadFormat)
+}
+
+func TestPackedIntSlice(t *testing.T) {
+	var sa, sb PackedIntSlice
+	readWrite(t, &sa, &sb, 1)
+	assert.Equal(t, "len(sb)", len(sb), 0)
+
+	// 10 elements in [100, 107], 3 bits each
+	sa = PackedIntSlice{100, 107, 101, 103, 100, 105, 106, 102, 104, 107}
+	readWrite(t, &sa, &sb, 1+2+1+4)
+	assert.Equal(t, "sb", sb, sa)
+
+	// All equal, no bits
+	sa = PackedIntSlice{7, 7, 7}
+	readWrite(t, &sa, &sb, 1+1+1)
+	assert.Equal(t, "sb", sb, sa)
+
+	// Too many equal elements for no bits
+	sa = PackedIntSlice{7, 7, 7, 7, 7, 7, 7, 7, 7, 7}
+	readWrite(t, &sa, &sb, 1+1+1+2)
+	assert.Equal(t, "sb", sb, sa)
+
+	sa = PackedIntSlice{math.MinInt64, -1, 0, math.MaxInt64}
+	readWrite(t, &sa, &sb, 1+10+1+32)
+	assert.Equal(t, "sb", sb, sa)
+
+	// Bad width
+	buf := bytesp.Slice("\x01\x00\x41")
+	assert.Equal(t, "ReadFrom", errorsp.Cause(sb.ReadFrom(&buf, len(buf))), ErrBadFormat)
+	// Data exceeds the known length
+	buf = bytesp.Slice("\x04\x00\x10\x01\x02")
+	assert.Equal(t, "ReadFrom", errorsp.Cause(sb.ReadFrom(&buf, len(buf))), ErrBadFormat)
+	buf = bytesp.Slice("\x04\x00\x08\x01\x02")
+	assert.Error(t, sb.ReadFrom(&buf, UNKNOWN_LEN))
+	// More elements of 0 bits than the bytes read
+	buf = bytesp.Slice("\x0a\x0e\x00")
+	assert.Equal(t, "ReadFrom", errorsp.Cause(sb.ReadFrom(&buf, UNKNOWN_LEN)), ErrBadFormat)
+}
+
+func TestBitset(t *testing.T) {
+	var b Bitset
+	b.Set(3)
+	b.Set(64)
+	b.Set(200)
+	assert.True(t, "b.Has(3)", b.Has(3))
+	assert.True(t, "b.Has(200)", b.Has(200))
+	assert.False(t, "b.Has(4)", b.Has(4))
+	assert.False(t, "b.Has(1000)", b.Has(1000))
+	assert.False(t, "b.Has(-1)", b.Has(-1))
+	assert.Equal(t, "b.Count()", b.Count(), 3)
+
+	var bb Bitset
+	readWrite(t, &b, &bb, 1+4*8)
+	assert.Equal(t, "bb", bb, b)
+
+	// Trailing zero words are not written.
+	b.Clear(200)
+	b.Clear(5000)
+	readWrite(t, &b, &bb, 1+2*8)
+	assert.Equal(t, "bb.Count()", bb.Count(), 2)
+	assert.False(t, "bb.Has(200)", bb.Has(200))
+
+	// Words in the capacity are cleared when growing.
+	bb.Set(130)
+	assert.Equal(t, "bb.Count()", bb.Count(), 3)
+
+	// Negative elements are ignored.
+	bb.Set(-1)
+	bb.Set(-100)
+	bb.Clear(-64)
+	assert.Equal(t, "len(bb)", len(bb), 3)
+	assert.Equal(t, "bb.Count()", bb.Count(), 3)
+	assert.False(t, "bb.Has(-1)", bb.Has(-1))
+
+	buf := bytesp.Slice("\x02\x01\x00\x00\x00\x00\x00\x00\x00")
+	assert.Equal(t, "ReadFrom", errorsp.Cause(bb.ReadFrom(&buf, len(buf))), ErrBadFormat)
+}
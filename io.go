@@ -10,12 +10,17 @@ following reasons:
 Since the serialization is flexible, one can also make some trade-offs between
 efficiency and convinience. E.g., if the data structure may be changed in the
 future, in the ReadFrom/WriteTo, God codec can be used to provide future
-compatibility.
+compatibility, or fields can be written as a record with RecordWriter, whose
+unknown fields are skipped by RecordReader.
+
+An io.Reader or io.Writer can be adapted with NewReader or NewWriter, and
+in-memory bytes can be read or written by BytesReader or BytesWriter.
 
 
 Sub packages:
-  mr  MapReduce library
-  kv  A file format storing key-value pairs.
+  mr    MapReduce library
+  kv    A file format storing key-value pairs.
+  keys  Keys whose serialized bytes sort in the same order as their values.
 */
 package sophie
 
@@ -126,6 +131,11 @@ func (i Int32) WriteTo(w Writer) error {
 	return errorsp.WithStacks(err)
 }
 
+// Sizer interface
+func (Int32) EncodedLen() int {
+	return 4
+}
+
 // SophieReader interface
 func (i *Int32) ReadFrom(r Reader, l int) error {
 	if l != UNKNOWN_LEN && l != 4 {
@@ -153,39 +163,23 @@ func NewVInt() Sophier {
 	return new(VInt)
 }
 
-// SophieWriter interface
+// SophieWriter interface. Negative values take 10 bytes.
 func (i VInt) WriteTo(w Writer) error {
-	var arr [10]byte
-	n := 0
-	for i > 0x7f {
-		arr[n] = byte(i&0x7f) | 0x80
-		n++
-		i >>= 7
-	}
-	arr[n] = byte(i)
-	n++
-	_, err := w.Write(arr[:n])
-	return errorsp.WithStacks(err)
+	return writeUVarint(w, uint64(i))
+}
+
+// Sizer interface
+func (i VInt) EncodedLen() int {
+	return uvarintLen(uint64(i))
 }
 
 // SophieReader interface
 func (i *VInt) ReadFrom(r Reader, l int) error {
-	var v VInt
-	b, err := r.ReadByte()
+	v, err := readUVarint(r)
 	if err != nil {
-		return errorsp.WithStacks(err)
-	}
-	v = VInt(b & 0x7f)
-	for n := uint(7); b&0x80 != 0; n += 7 {
-		if b, err = r.ReadByte(); err != nil {
-			if errorsp.Cause(err) == io.EOF {
-				return errorsp.WithStacks(io.ErrUnexpectedEOF)
-			}
-			return errorsp.WithStacks(err)
-		}
-		v |= VInt(b&0x7f) << n
+		return err
 	}
-	*i = v
+	*i = VInt(v)
 	return nil
 }
 
@@ -206,35 +200,23 @@ func NewRawVInt() Sophier {
 	return new(RawVInt)
 }
 
-// SophieWriter interface
+// SophieWriter interface. Negative values take 8 bytes.
 func (i RawVInt) WriteTo(w Writer) error {
-	var arr [8]byte
-	n := 0
-	for i != 0 {
-		arr[n] = byte(i & 0xff)
-		n++
-		i >>= 8
-	}
-	_, err := w.Write(arr[:n])
-	return errorsp.WithStacks(err)
+	return writeRawUint(w, uint64(i))
+}
+
+// Sizer interface
+func (i RawVInt) EncodedLen() int {
+	return rawUintLen(uint64(i))
 }
 
 // SophieReader interface
 func (i *RawVInt) ReadFrom(r Reader, l int) error {
-	if l < 0 {
-		return errorsp.WithStacksAndMessage(ErrBadFormat, "l = %d", l)
-	}
-	var v RawVInt
-	n := uint(0)
-	for ; l > 0; l-- {
-		b, err := r.ReadByte()
-		if err != nil {
-			return errorsp.WithStacks(err)
-		}
-		v |= (RawVInt(b) & 0xff) << n
-		n += 8
+	v, err := readRawUint(r, l)
+	if err != nil {
+		return err
 	}
-	*i = v
+	*i = RawVInt(v)
 	return nil
 }
 
@@ -263,15 +245,49 @@ func (ba ByteSlice) WriteTo(w Writer) error {
 	return errorsp.WithStacks(err)
 }
 
-// SophieReader interface
-func (ba *ByteSlice) ReadFrom(r Reader, l int) error {
+// Sizer interface
+func (ba ByteSlice) EncodedLen() int {
+	return uvarintLen(uint64(len(ba))) + len(ba)
+}
+
+// readLen reads the length of a ByteSlice and checks it.
+func (ByteSlice) readLen(r Reader, l int) (int, error) {
 	var sz VInt
 	if err := sz.ReadFrom(r, UNKNOWN_LEN); err != nil {
-		return errorsp.WithStacks(err)
+		return 0, errorsp.WithStacks(err)
+	}
+	if err := DecodeLimits.CheckLen(int(sz)); err != nil {
+		return 0, err
 	}
-	*ba = make(ByteSlice, sz)
+	if l != UNKNOWN_LEN && int(sz) > l {
+		return 0, errorsp.WithStacksAndMessage(ErrBadFormat, "length %d exceeds l = %d", sz, l)
+	}
+	return int(sz), nil
+}
+
+// SophieReader interface. The capacity of ba is reused if large enough. If r
+// is an Allocator, the bytes are allocated by it.
+func (ba *ByteSlice) ReadFrom(r Reader, l int) error {
+	sz, err := ba.readLen(r, l)
+	if err != nil {
+		return err
+	}
+	*ba = allocBytes(r, *ba, sz)
 
-	_, err := io.ReadFull(r, *ba)
+	_, err = io.ReadFull(r, *ba)
+	return errorsp.WithStacks(err)
+}
+
+// readFrom is similar to ReadFrom but never allocates from an Allocator. It
+// is used for reading to scratch buffers.
+func (ba *ByteSlice) readFrom(r Reader, l int) error {
+	sz, err := ba.readLen(r, l)
+	if err != nil {
+		return err
+	}
+	*ba = growBytes(*ba, sz)
+
+	_, err = io.ReadFull(r, *ba)
 	return errorsp.WithStacks(err)
 }
 
@@ -290,12 +306,21 @@ func (ba RawByteSlice) WriteTo(w Writer) error {
 	return errorsp.WithStacks(err)
 }
 
-// SophieReader interface
+// Sizer interface
+func (ba RawByteSlice) EncodedLen() int {
+	return len(ba)
+}
+
+// SophieReader interface. The capacity of ba is reused if large enough. If r
+// is an Allocator, the bytes are allocated by it.
 func (ba *RawByteSlice) ReadFrom(r Reader, sz int) error {
 	if sz < 0 {
 		return errorsp.WithStacksAndMessage(ErrBadFormat, "expecting a size but get %d", sz)
 	}
-	*ba = make(RawByteSlice, sz)
+	if err := DecodeLimits.CheckLen(sz); err != nil {
+		return err
+	}
+	*ba = allocBytes(r, *ba, sz)
 
 	n, err := io.ReadFull(r, *ba)
 	if n != sz {
@@ -317,13 +342,19 @@ func (s String) WriteTo(w Writer) error {
 	return ByteSlice(s).WriteTo(w)
 }
 
+// Sizer interface
+func (s String) EncodedLen() int {
+	return uvarintLen(uint64(len(s))) + len(s)
+}
+
 // SophieReader interface
 func (s *String) ReadFrom(r Reader, l int) error {
-	var ba ByteSlice
-	if err := ba.ReadFrom(r, l); err != nil {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	if err := (*ByteSlice)(buf).readFrom(r, l); err != nil {
 		return err
 	}
-	*s = String(ba)
+	*s = String(*buf)
 
 	return nil
 }
@@ -363,15 +394,16 @@ func ReadStringSlice(r Reader, sl *[]string) (err error) {
 	if err := l.ReadFrom(r, -1); err != nil {
 		return errorsp.WithStacks(err)
 	}
-	if cap(*sl) < int(l) {
-		*sl = make([]string, l)
-	} else {
-		*sl = (*sl)[:l]
+	if err := DecodeLimits.CheckCount(int(l)); err != nil {
+		return err
 	}
-	for i := range *sl {
-		if err := (*String)(&(*sl)[i]).ReadFrom(r, -1); err != nil {
+	*sl = prealloc(*sl, int(l))
+	for i := 0; i < int(l); i++ {
+		var s String
+		if err := s.ReadFrom(r, -1); err != nil {
 			return errorsp.WithStacks(err)
 		}
+		*sl = append(*sl, string(s))
 	}
 	return nil
 }
@@ -389,13 +421,26 @@ func (s RawString) WriteTo(w Writer) error {
 	return RawByteSlice(s).WriteTo(w)
 }
 
+// Sizer interface
+func (s RawString) EncodedLen() int {
+	return len(s)
+}
+
 // SophieReader interface
 func (s *RawString) ReadFrom(r Reader, l int) error {
-	var ba RawByteSlice
-	if err := ba.ReadFrom(r, l); err != nil {
+	if l < 0 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "expecting a size but get %d", l)
+	}
+	if err := DecodeLimits.CheckLen(l); err != nil {
 		return err
 	}
-	*s = RawString(ba)
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	*buf = growBytes(*buf, l)
+	if _, err := io.ReadFull(r, *buf); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	*s = RawString(*buf)
 
 	return nil
 }
@@ -422,6 +467,11 @@ func (Null) WriteTo(w Writer) error {
 	return nil
 }
 
+// Sizer interface
+func (Null) EncodedLen() int {
+	return 0
+}
+
 // SophieReader interface
 func (Null) ReadFrom(r Reader, l int) error {
 	if l != UNKNOWN_LEN && l != 0 {
@@ -430,7 +480,8 @@ func (Null) ReadFrom(r Reader, l int) error {
 	return nil
 }
 
-// Time is a time.Time, and *Time implements Sophie interface.
+// Time is a time.Time, and *Time implements Sophie interface. Time does not
+// implement Sizer, since its length is only known after marshalling it.
 type Time time.Time
 
 // Returns a new instance of *Time as a Sophier
@@ -449,9 +500,10 @@ func (t Time) WriteTo(w Writer) error {
 
 // SophieReader interface
 func (t *Time) ReadFrom(r Reader, l int) error {
-	var bytes ByteSlice
-	if err := (&bytes).ReadFrom(r, l); err != nil {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	if err := (*ByteSlice)(buf).readFrom(r, l); err != nil {
 		return errorsp.WithStacks(err)
 	}
-	return errorsp.WithStacks(((*time.Time)(t)).UnmarshalBinary(bytes))
+	return errorsp.WithStacks(((*time.Time)(t)).UnmarshalBinary(*buf))
 }
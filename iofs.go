@@ -0,0 +1,238 @@
+package sophie
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// ErrReadOnly is the error, wrapped in an *os.PathError, returned by the
+// writing methods of a FileSystem returned by FromIOFS.
+var ErrReadOnly = errors.New("read-only file system")
+
+// FromIOFS returns a read-only FileSystem reading files from fsys, e.g. an
+// embed.FS, an fstest.MapFS or an os.DirFS. Paths are converted to the
+// unrooted slash-separated paths of io/fs, i.e. "/a/b", "a/b" and "./a/b" are
+// all "a/b". Create, Mkdir, Remove and Rename return ErrReadOnly.
+func FromIOFS(fsys fs.FS) FileSystem {
+	return ioFileSystem{fsys: fsys}
+}
+
+type ioFileSystem struct {
+	fsys fs.FS
+}
+
+// ioPath converts a path to that of io/fs.
+func ioPath(op, fn string) (string, error) {
+	p := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(fn)), "/")
+	if p == "" {
+		p = "."
+	}
+	if !fs.ValidPath(p) {
+		return "", &os.PathError{Op: op, Path: fn, Err: fs.ErrInvalid}
+	}
+	return p, nil
+}
+
+// FileSystem interface
+func (ifs ioFileSystem) Create(fn string) (WriteCloser, error) {
+	return nil, &os.PathError{Op: "open", Path: fn, Err: ErrReadOnly}
+}
+
+// FileSystem interface
+func (ifs ioFileSystem) Mkdir(p string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: p, Err: ErrReadOnly}
+}
+
+// FileSystem interface
+func (ifs ioFileSystem) Remove(fn string) error {
+	return &os.PathError{Op: "remove", Path: fn, Err: ErrReadOnly}
+}
+
+// FileSystem interface
+func (ifs ioFileSystem) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrReadOnly}
+}
+
+// FileSystem interface. If the fs.File implements io.Seeker, e.g. those of
+// embed.FS, fstest.MapFS and os.DirFS, Skip of the ReadCloser seeks.
+func (ifs ioFileSystem) Open(fn string) (ReadCloser, error) {
+	p, err := ioPath("open", fn)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ifs.fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, &os.PathError{Op: "read", Path: fn, Err: syscall.EISDIR}
+	}
+	return ioFileReader{Reader: NewReader(f), Closer: f}, nil
+}
+
+type ioFileReader struct {
+	Reader
+	io.Closer
+}
+
+// FileSystem interface
+func (ifs ioFileSystem) ReadDir(dir string) ([]os.FileInfo, error) {
+	p, err := ioPath("open", dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(ifs.fsys, p)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// FileSystem interface
+func (ifs ioFileSystem) Stat(fn string) (os.FileInfo, error) {
+	p, err := ioPath("stat", fn)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(ifs.fsys, p)
+}
+
+// ToIOFS returns an fs.FS reading files from fsys, which also implements
+// fs.ReadDirFS and fs.StatFS. The names are passed to fsys unchanged, so they
+// are relative to the current directory for LocalFS, and to the root for
+// MemFS.
+func ToIOFS(fsys FileSystem) fs.FS {
+	return sophieIOFS{fsys: fsys}
+}
+
+type sophieIOFS struct {
+	fsys FileSystem
+}
+
+// fs.FS interface
+func (sfs sophieIOFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fi, err := sfs.fsys.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return &ioDir{fsys: sfs.fsys, name: name, info: fi}, nil
+	}
+	r, err := sfs.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ioFile{ReadCloser: r, info: fi}, nil
+}
+
+// fs.ReadDirFS interface
+func (sfs sophieIOFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	infos, err := sfs.fsys.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// fs.StatFS interface
+func (sfs sophieIOFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return sfs.fsys.Stat(name)
+}
+
+// ioFile is an fs.File of a file of a FileSystem.
+type ioFile struct {
+	ReadCloser
+	info fs.FileInfo
+}
+
+// fs.File interface
+func (f *ioFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// ioDir is an fs.ReadDirFile of a directory of a FileSystem. The entries are
+// read at the first call to ReadDir.
+type ioDir struct {
+	fsys    FileSystem
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+// fs.File interface
+func (d *ioDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+// fs.File interface
+func (d *ioDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+// fs.File interface
+func (d *ioDir) Close() error {
+	return nil
+}
+
+// fs.ReadDirFile interface
+func (d *ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := sophieIOFS{fsys: d.fsys}.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
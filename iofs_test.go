@@ -0,0 +1,70 @@
+package sophie
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestFromIOFS(t *testing.T) {
+	var buf BytesWriter
+	assert.NoError(t, String("abc").WriteTo(&buf))
+	assert.NoError(t, VInt(300).WriteTo(&buf))
+	fsys := FromIOFS(fstest.MapFS{
+		"d/f": &fstest.MapFile{Data: buf.Bytes()},
+		"d/g": &fstest.MapFile{Data: []byte("g")},
+	})
+
+	r, err := fsys.Open("/d/f")
+	assert.NoErrorOrDie(t, err)
+	n, err := r.Skip(4)
+	assert.NoError(t, err)
+	assert.Equal(t, "n", n, int64(4))
+	var v VInt
+	assert.NoError(t, v.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "v", v, VInt(300))
+	assert.NoError(t, r.Close())
+
+	fi, err := fsys.Stat("d/f")
+	assert.NoError(t, err)
+	assert.Equal(t, "Size", fi.Size(), int64(6))
+
+	infos, err := fsys.ReadDir("./d")
+	assert.NoError(t, err)
+	assert.Equal(t, "len(infos)", len(infos), 2)
+	assert.Equal(t, "infos[1].Name", infos[1].Name(), "g")
+
+	_, err = fsys.Open("/d")
+	assert.Error(t, err)
+	_, err = fsys.Open("/e")
+	assert.True(t, "ErrNotExist", errors.Is(err, fs.ErrNotExist))
+
+	_, err = fsys.Create("/d/h")
+	assert.True(t, "ErrReadOnly", errors.Is(err, ErrReadOnly))
+	assert.True(t, "ErrReadOnly", errors.Is(fsys.Mkdir("/e", 0755), ErrReadOnly))
+	assert.True(t, "ErrReadOnly", errors.Is(fsys.Remove("/d/f"), ErrReadOnly))
+	assert.True(t, "ErrReadOnly", errors.Is(fsys.Rename("/d/f", "/d/h"), ErrReadOnly))
+}
+
+func TestToIOFS(t *testing.T) {
+	mem := NewMemFS()
+	assert.NoError(t, mem.Mkdir("/a/b", 0755))
+	assert.NoError(t, mem.Mkdir("/c", 0755))
+	for _, fn := range []string{"/a/f", "/a/b/g", "/h"} {
+		w, err := mem.Create(fn)
+		assert.NoErrorOrDie(t, err)
+		assert.NoError(t, String(fn).WriteTo(w))
+		assert.NoError(t, w.Close())
+	}
+	fsys := ToIOFS(mem)
+	assert.NoError(t, fstest.TestFS(fsys, "a/f", "a/b/g", "h", "c"))
+
+	data, err := fs.ReadFile(fsys, "a/b/g")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data), "\x06/a/b/g")
+	_, err = fs.ReadFile(fsys, "/h")
+	assert.Error(t, err)
+}
@@ -0,0 +1,322 @@
+package sophie
+
+import (
+	"bytes"
+	"container/heap"
+	"io"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+)
+
+// closeAll closes all the closers and returns the first error.
+func closeAll[C io.Closer](cs []C) error {
+	var firstErr error
+	for _, c := range cs {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type filterIterator struct {
+	IterateCloser
+	pred func(key, val SophieReader) bool
+}
+
+// FilterIterator returns an IterateCloser returning the pairs of it for which
+// pred returns true. Closing it closes it.
+func FilterIterator(it IterateCloser, pred func(key, val SophieReader) bool) IterateCloser {
+	return &filterIterator{IterateCloser: it, pred: pred}
+}
+
+// Iterator interface
+func (fi *filterIterator) Next(key, val SophieReader) error {
+	for {
+		if err := fi.IterateCloser.Next(key, val); err != nil {
+			return err
+		}
+		if fi.pred(key, val) {
+			return nil
+		}
+	}
+}
+
+type transformIterator struct {
+	IterateCloser
+	srcKey, srcVal Sophier
+	f              func(srcKey, srcVal Sophier, key, val SophieReader) error
+}
+
+// TransformIterator returns an IterateCloser reading the pairs of it into
+// Sophiers returned by newKey and newVal, and converting them to the key and
+// value passed to Next by calling f. Closing it closes it.
+func TransformIterator(it IterateCloser, newKey, newVal func() Sophier,
+	f func(srcKey, srcVal Sophier, key, val SophieReader) error) IterateCloser {
+	return &transformIterator{
+		IterateCloser: it,
+		srcKey:        newKey(),
+		srcVal:        newVal(),
+		f:             f,
+	}
+}
+
+// Iterator interface
+func (ti *transformIterator) Next(key, val SophieReader) error {
+	if err := ti.IterateCloser.Next(ti.srcKey, ti.srcVal); err != nil {
+		return err
+	}
+	return ti.f(ti.srcKey, ti.srcVal, key, val)
+}
+
+type limitIterator struct {
+	IterateCloser
+	left int
+}
+
+// LimitIterator returns an IterateCloser returning at most the first n pairs
+// of it. Closing it closes it.
+func LimitIterator(it IterateCloser, n int) IterateCloser {
+	return &limitIterator{IterateCloser: it, left: n}
+}
+
+// Iterator interface
+func (li *limitIterator) Next(key, val SophieReader) error {
+	if li.left <= 0 {
+		return io.EOF
+	}
+	if err := li.IterateCloser.Next(key, val); err != nil {
+		return err
+	}
+	li.left--
+	return nil
+}
+
+type skipIterator struct {
+	IterateCloser
+	n int
+}
+
+// SkipIterator returns an IterateCloser skipping the first n pairs of it.
+// Closing it closes it.
+func SkipIterator(it IterateCloser, n int) IterateCloser {
+	return &skipIterator{IterateCloser: it, n: n}
+}
+
+// Iterator interface
+func (si *skipIterator) Next(key, val SophieReader) error {
+	for ; si.n > 0; si.n-- {
+		if err := si.IterateCloser.Next(key, val); err != nil {
+			return err
+		}
+	}
+	return si.IterateCloser.Next(key, val)
+}
+
+type teeIterator struct {
+	IterateCloser
+	c Collector
+}
+
+// TeeIterator returns an IterateCloser returning the pairs of it, which are
+// also collected to c. The keys and values passed to Next must be
+// SophieWriters, e.g. Sophiers. Closing it closes it but not c.
+func TeeIterator(it IterateCloser, c Collector) IterateCloser {
+	return &teeIterator{IterateCloser: it, c: c}
+}
+
+// Iterator interface
+func (ti *teeIterator) Next(key, val SophieReader) error {
+	if err := ti.IterateCloser.Next(key, val); err != nil {
+		return err
+	}
+	k, ok := key.(SophieWriter)
+	if !ok {
+		return errorsp.NewWithStacks("key of type %T is not a SophieWriter", key)
+	}
+	v, ok := val.(SophieWriter)
+	if !ok {
+		return errorsp.NewWithStacks("value of type %T is not a SophieWriter", val)
+	}
+	return ti.c.Collect(k, v)
+}
+
+type concatIterator struct {
+	its []IterateCloser
+	cur int
+}
+
+// ConcatIterators returns an IterateCloser returning the pairs of its one by
+// one. An iterator is closed once it is exhausted, and closing the returned
+// IterateCloser closes the rest.
+func ConcatIterators(its ...IterateCloser) IterateCloser {
+	return &concatIterator{its: its}
+}
+
+// Iterator interface
+func (ci *concatIterator) Next(key, val SophieReader) error {
+	for ci.cur < len(ci.its) {
+		err := ci.its[ci.cur].Next(key, val)
+		if errorsp.Cause(err) != io.EOF {
+			return err
+		}
+		err = ci.its[ci.cur].Close()
+		ci.cur++
+		if err != nil {
+			return err
+		}
+	}
+	return io.EOF
+}
+
+// io.Closer interface
+func (ci *concatIterator) Close() error {
+	its := ci.its[ci.cur:]
+	ci.cur = len(ci.its)
+	return closeAll(its)
+}
+
+// mergeSource is an iterator merged by MergeSortedIterators with its current
+// pair.
+type mergeSource struct {
+	it       IterateCloser
+	index    int
+	key, val Sophier
+	keyBytes bytesp.Slice
+}
+
+// mergeHeap is a heap.Interface of mergeSources with the smallest key bytes
+// at the top. Pairs with equal keys are returned in the order of iterators.
+type mergeHeap []*mergeSource
+
+func (h mergeHeap) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].keyBytes, h[j].keyBytes); c != 0 {
+		return c < 0
+	}
+	return h[i].index < h[j].index
+}
+
+func (h mergeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*mergeSource))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type mergedIterator struct {
+	its     []IterateCloser
+	newKey  func() Sophier
+	newVal  func() Sophier
+	heap    mergeHeap
+	started bool
+	valBuf  bytesp.Slice
+	reader  BytesReader
+	// The error returned by Next, if any, returned by all later calls, since
+	// a source may be in the middle of a pair or left out of the heap.
+	err error
+}
+
+// MergeSortedIterators returns an IterateCloser merging iterators whose pairs
+// are sorted by the serialized bytes of the keys, e.g. kv files written by a
+// sorting job, into a single sorted sequence. The pairs of its are read into
+// Sophiers returned by newKey and newVal, and copied to the key and value
+// passed to Next through their serialized bytes. Closing it closes all the
+// iterators.
+func MergeSortedIterators(newKey, newVal func() Sophier, its ...IterateCloser) IterateCloser {
+	return &mergedIterator{its: its, newKey: newKey, newVal: newVal}
+}
+
+// fetch reads the next pair of src. It returns false if src is exhausted.
+func (mi *mergedIterator) fetch(src *mergeSource) (bool, error) {
+	if err := src.it.Next(src.key, src.val); err != nil {
+		if errorsp.Cause(err) == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	src.keyBytes.Reset()
+	if err := src.key.WriteTo(&src.keyBytes); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (mi *mergedIterator) start() error {
+	mi.started = true
+	for i, it := range mi.its {
+		src := &mergeSource{it: it, index: i, key: mi.newKey(), val: mi.newVal()}
+		ok, err := mi.fetch(src)
+		if err != nil {
+			return err
+		}
+		if ok {
+			mi.heap = append(mi.heap, src)
+		}
+	}
+	heap.Init(&mi.heap)
+	return nil
+}
+
+// Iterator interface
+func (mi *mergedIterator) Next(key, val SophieReader) error {
+	if mi.err != nil {
+		return mi.err
+	}
+	if err := mi.next(key, val); err != nil {
+		mi.err = err
+		return err
+	}
+	return nil
+}
+
+func (mi *mergedIterator) next(key, val SophieReader) error {
+	if !mi.started {
+		if err := mi.start(); err != nil {
+			return err
+		}
+	}
+	if len(mi.heap) == 0 {
+		return io.EOF
+	}
+	src := mi.heap[0]
+	mi.reader.Reset(src.keyBytes)
+	if err := key.ReadFrom(&mi.reader, len(src.keyBytes)); err != nil {
+		return err
+	}
+	mi.valBuf.Reset()
+	if err := src.val.WriteTo(&mi.valBuf); err != nil {
+		return err
+	}
+	mi.reader.Reset(mi.valBuf)
+	if err := val.ReadFrom(&mi.reader, len(mi.valBuf)); err != nil {
+		return err
+	}
+	ok, err := mi.fetch(src)
+	if err != nil {
+		return err
+	}
+	if ok {
+		heap.Fix(&mi.heap, 0)
+	} else {
+		heap.Pop(&mi.heap)
+	}
+	return nil
+}
+
+// io.Closer interface
+func (mi *mergedIterator) Close() error {
+	return closeAll(mi.its)
+}
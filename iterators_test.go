@@ -0,0 +1,134 @@
+package sophie
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+// sliceIterator returns an IterateCloser of pairs with keys and values both
+// in ks. closed is increased when it is closed.
+func sliceIterator(closed *int, ks ...int) IterateCloser {
+	return &IterateCloserStruct{
+		NextF: func(key, val SophieReader) error {
+			if len(ks) == 0 {
+				return io.EOF
+			}
+			*key.(*VInt), *val.(*VInt) = VInt(ks[0]), VInt(ks[0])
+			ks = ks[1:]
+			return nil
+		},
+		CloserF: func() error {
+			*closed++
+			return nil
+		},
+	}
+}
+
+// readAll returns the keys of all the pairs of it, and checks the values.
+func readAll(t *testing.T, it Iterator) []int {
+	var ks []int
+	var key, val VInt
+	for {
+		err := it.Next(&key, &val)
+		if errorsp.Cause(err) == io.EOF {
+			return ks
+		}
+		if !assert.NoError(t, err) {
+			return ks
+		}
+		ks = append(ks, int(key))
+		assert.Equal(t, "val", val, key)
+	}
+}
+
+func TestFilterLimitSkipIterator(t *testing.T) {
+	var closed int
+	it := FilterIterator(sliceIterator(&closed, 1, 2, 3, 4, 5, 6, 7), func(key, val SophieReader) bool {
+		return *key.(*VInt)%2 == 1
+	})
+	it = LimitIterator(SkipIterator(it, 1), 2)
+	assert.Equal(t, "keys", readAll(t, it), []int{3, 5})
+	assert.NoError(t, it.Close())
+	assert.Equal(t, "closed", closed, 1)
+
+	it = SkipIterator(sliceIterator(&closed, 1, 2), 3)
+	assert.Equal(t, "keys", readAll(t, it), []int(nil))
+}
+
+func TestTransformIterator(t *testing.T) {
+	var closed int
+	it := TransformIterator(sliceIterator(&closed, 1, 2), NewVInt, NewVInt,
+		func(srcKey, srcVal Sophier, key, val SophieReader) error {
+			*key.(*VInt) = *srcKey.(*VInt) * 10
+			*val.(*VInt) = *srcVal.(*VInt) * 10
+			return nil
+		})
+	assert.Equal(t, "keys", readAll(t, it), []int{10, 20})
+	assert.NoError(t, it.Close())
+	assert.Equal(t, "closed", closed, 1)
+}
+
+func TestTeeIterator(t *testing.T) {
+	var closed int
+	var collected []int
+	it := TeeIterator(sliceIterator(&closed, 1, 2), CollectorF(func(key, val SophieWriter) error {
+		collected = append(collected, int(*key.(*VInt)))
+		return nil
+	}))
+	assert.Equal(t, "keys", readAll(t, it), []int{1, 2})
+	assert.Equal(t, "collected", collected, []int{1, 2})
+	assert.NoError(t, it.Close())
+	assert.Equal(t, "closed", closed, 1)
+}
+
+func TestConcatIterators(t *testing.T) {
+	var closed int
+	it := ConcatIterators(sliceIterator(&closed, 1, 2), sliceIterator(&closed), sliceIterator(&closed, 3))
+	assert.Equal(t, "keys", readAll(t, LimitIterator(it, 2)), []int{1, 2})
+	assert.Equal(t, "closed", closed, 0)
+	assert.Equal(t, "keys", readAll(t, it), []int{3})
+	assert.Equal(t, "closed", closed, 3)
+	assert.NoError(t, it.Close())
+	assert.Equal(t, "closed", closed, 3)
+
+	closed = 0
+	it = ConcatIterators(sliceIterator(&closed, 1), sliceIterator(&closed, 2))
+	assert.Equal(t, "keys", readAll(t, LimitIterator(it, 1)), []int{1})
+	assert.NoError(t, it.Close())
+	assert.Equal(t, "closed", closed, 2)
+}
+
+func TestMergeSortedIterators(t *testing.T) {
+	var closed int
+	it := MergeSortedIterators(NewVInt, NewVInt,
+		sliceIterator(&closed, 1, 4, 7),
+		sliceIterator(&closed),
+		sliceIterator(&closed, 2, 4, 300),
+		sliceIterator(&closed, 3, 5, 6, 200))
+	// Sorted by the serialized bytes, so 300 (ac 02) is before 200 (c8 01).
+	assert.Equal(t, "keys", readAll(t, it), []int{1, 2, 3, 4, 4, 5, 6, 7, 300, 200})
+	assert.NoError(t, it.Close())
+	assert.Equal(t, "closed", closed, 4)
+
+	it = MergeSortedIterators(NewVInt, NewVInt)
+	assert.Equal(t, "keys", readAll(t, it), []int(nil))
+}
+
+func TestMergeSortedIterators_Error(t *testing.T) {
+	var closed int
+	failed := &IterateCloserStruct{
+		NextF: func(key, val SophieReader) error {
+			return errors.New("failed")
+		},
+	}
+	it := MergeSortedIterators(NewVInt, NewVInt, sliceIterator(&closed, 1, 2), failed)
+	var key, val VInt
+	// A failed source is not dropped silently by later calls.
+	for i := 0; i < 3; i++ {
+		assert.Error(t, it.Next(&key, &val))
+	}
+}
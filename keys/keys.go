@@ -0,0 +1,631 @@
+/*
+Package keys provides Sophiers whose serialized bytes sort in the same order as
+their values. They are designed to be used as keys of kv pairs in mr jobs,
+where the sorters compare keys by their serialized bytes.
+
+Encodings:
+
+	Int32/Int64       big-endian with the sign bit flipped
+	Uint32/Uint64     big-endian
+	Float32/Float64   IEEE 754 bits, sign bit flipped for positives, all bits
+	                  flipped for negatives
+	UnixSeconds/UnixMillis/UnixNanos
+	                  Int64 of the Unix time in the unit
+	String/Bytes      0x00 escaped as 0x00 0xFF, terminated by 0x00 0x01
+	Desc              all bytes of the wrapped key inverted
+	Tuple             concatenation of the elements
+
+All encodings are self-delimiting, so they can be combined in a Tuple or
+wrapped in a Desc.
+*/
+package keys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/sophie"
+)
+
+func writeUint64(w sophie.Writer, v uint64) error {
+	var arr [8]byte
+	binary.BigEndian.PutUint64(arr[:], v)
+	_, err := w.Write(arr[:])
+	return errorsp.WithStacks(err)
+}
+
+func readUint64(r sophie.Reader, l int) (uint64, error) {
+	if l != sophie.UNKNOWN_LEN && l != 8 {
+		return 0, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "l = %d", l)
+	}
+	var arr [8]byte
+	if _, err := io.ReadFull(r, arr[:]); err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	return binary.BigEndian.Uint64(arr[:]), nil
+}
+
+func writeUint32(w sophie.Writer, v uint32) error {
+	var arr [4]byte
+	binary.BigEndian.PutUint32(arr[:], v)
+	_, err := w.Write(arr[:])
+	return errorsp.WithStacks(err)
+}
+
+func readUint32(r sophie.Reader, l int) (uint32, error) {
+	if l != sophie.UNKNOWN_LEN && l != 4 {
+		return 0, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "l = %d", l)
+	}
+	var arr [4]byte
+	if _, err := io.ReadFull(r, arr[:]); err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	return binary.BigEndian.Uint32(arr[:]), nil
+}
+
+// *Int32 implements Sophie interface with an order-preserving encoding.
+type Int32 int32
+
+// Returns a new instance of *Int32 as a Sophier
+func NewInt32() sophie.Sophier {
+	return new(Int32)
+}
+
+// SophieWriter interface
+func (i Int32) WriteTo(w sophie.Writer) error {
+	return writeUint32(w, uint32(i)^(1<<31))
+}
+
+// Sizer interface
+func (Int32) EncodedLen() int {
+	return 4
+}
+
+// SophieReader interface
+func (i *Int32) ReadFrom(r sophie.Reader, l int) error {
+	v, err := readUint32(r, l)
+	if err != nil {
+		return err
+	}
+	*i = Int32(v ^ (1 << 31))
+	return nil
+}
+
+func (i *Int32) Val() int32 {
+	return int32(*i)
+}
+
+// *Int64 implements Sophie interface with an order-preserving encoding.
+type Int64 int64
+
+// Returns a new instance of *Int64 as a Sophier
+func NewInt64() sophie.Sophier {
+	return new(Int64)
+}
+
+// SophieWriter interface
+func (i Int64) WriteTo(w sophie.Writer) error {
+	return writeUint64(w, uint64(i)^(1<<63))
+}
+
+// Sizer interface
+func (Int64) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (i *Int64) ReadFrom(r sophie.Reader, l int) error {
+	v, err := readUint64(r, l)
+	if err != nil {
+		return err
+	}
+	*i = Int64(v ^ (1 << 63))
+	return nil
+}
+
+func (i *Int64) Val() int64 {
+	return int64(*i)
+}
+
+// *Uint32 implements Sophie interface with an order-preserving encoding.
+type Uint32 uint32
+
+// Returns a new instance of *Uint32 as a Sophier
+func NewUint32() sophie.Sophier {
+	return new(Uint32)
+}
+
+// SophieWriter interface
+func (i Uint32) WriteTo(w sophie.Writer) error {
+	return writeUint32(w, uint32(i))
+}
+
+// Sizer interface
+func (Uint32) EncodedLen() int {
+	return 4
+}
+
+// SophieReader interface
+func (i *Uint32) ReadFrom(r sophie.Reader, l int) error {
+	v, err := readUint32(r, l)
+	if err != nil {
+		return err
+	}
+	*i = Uint32(v)
+	return nil
+}
+
+func (i *Uint32) Val() uint32 {
+	return uint32(*i)
+}
+
+// *Uint64 implements Sophie interface with an order-preserving encoding.
+type Uint64 uint64
+
+// Returns a new instance of *Uint64 as a Sophier
+func NewUint64() sophie.Sophier {
+	return new(Uint64)
+}
+
+// SophieWriter interface
+func (i Uint64) WriteTo(w sophie.Writer) error {
+	return writeUint64(w, uint64(i))
+}
+
+// Sizer interface
+func (Uint64) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (i *Uint64) ReadFrom(r sophie.Reader, l int) error {
+	v, err := readUint64(r, l)
+	if err != nil {
+		return err
+	}
+	*i = Uint64(v)
+	return nil
+}
+
+func (i *Uint64) Val() uint64 {
+	return uint64(*i)
+}
+
+// *Float32 implements Sophie interface with an order-preserving encoding.
+// -0 sorts before +0. NaNs sort after +Inf if the sign bit is cleared, before
+// -Inf otherwise.
+type Float32 float32
+
+// Returns a new instance of *Float32 as a Sophier
+func NewFloat32() sophie.Sophier {
+	return new(Float32)
+}
+
+// SophieWriter interface
+func (f Float32) WriteTo(w sophie.Writer) error {
+	bits := math.Float32bits(float32(f))
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits ^= 1 << 31
+	}
+	return writeUint32(w, bits)
+}
+
+// Sizer interface
+func (Float32) EncodedLen() int {
+	return 4
+}
+
+// SophieReader interface
+func (f *Float32) ReadFrom(r sophie.Reader, l int) error {
+	bits, err := readUint32(r, l)
+	if err != nil {
+		return err
+	}
+	if bits&(1<<31) != 0 {
+		bits ^= 1 << 31
+	} else {
+		bits = ^bits
+	}
+	*f = Float32(math.Float32frombits(bits))
+	return nil
+}
+
+func (f *Float32) Val() float32 {
+	return float32(*f)
+}
+
+// *Float64 implements Sophie interface with an order-preserving encoding.
+// -0 sorts before +0. NaNs sort after +Inf if the sign bit is cleared, before
+// -Inf otherwise.
+type Float64 float64
+
+// Returns a new instance of *Float64 as a Sophier
+func NewFloat64() sophie.Sophier {
+	return new(Float64)
+}
+
+// SophieWriter interface
+func (f Float64) WriteTo(w sophie.Writer) error {
+	bits := math.Float64bits(float64(f))
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits ^= 1 << 63
+	}
+	return writeUint64(w, bits)
+}
+
+// Sizer interface
+func (Float64) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (f *Float64) ReadFrom(r sophie.Reader, l int) error {
+	bits, err := readUint64(r, l)
+	if err != nil {
+		return err
+	}
+	if bits&(1<<63) != 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	*f = Float64(math.Float64frombits(bits))
+	return nil
+}
+
+func (f *Float64) Val() float64 {
+	return float64(*f)
+}
+
+// UnixSeconds is a time.Time, and *UnixSeconds implements Sophie interface
+// with an order-preserving encoding of the Unix seconds (as an Int64). The
+// sub-second part is truncated and the time read is in UTC.
+type UnixSeconds time.Time
+
+// Returns a new instance of *UnixSeconds as a Sophier
+func NewUnixSeconds() sophie.Sophier {
+	return new(UnixSeconds)
+}
+
+// SophieWriter interface
+func (t UnixSeconds) WriteTo(w sophie.Writer) error {
+	return Int64(time.Time(t).Unix()).WriteTo(w)
+}
+
+// Sizer interface
+func (UnixSeconds) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (t *UnixSeconds) ReadFrom(r sophie.Reader, l int) error {
+	var s Int64
+	if err := s.ReadFrom(r, l); err != nil {
+		return err
+	}
+	*t = UnixSeconds(time.Unix(int64(s), 0).UTC())
+	return nil
+}
+
+func (t *UnixSeconds) Val() time.Time {
+	return time.Time(*t)
+}
+
+// UnixMillis is a time.Time, and *UnixMillis implements Sophie interface with
+// an order-preserving encoding of the Unix milliseconds (as an Int64). The
+// sub-millisecond part is truncated and the time read is in UTC.
+type UnixMillis time.Time
+
+// Returns a new instance of *UnixMillis as a Sophier
+func NewUnixMillis() sophie.Sophier {
+	return new(UnixMillis)
+}
+
+// SophieWriter interface
+func (t UnixMillis) WriteTo(w sophie.Writer) error {
+	return Int64(time.Time(t).UnixMilli()).WriteTo(w)
+}
+
+// Sizer interface
+func (UnixMillis) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (t *UnixMillis) ReadFrom(r sophie.Reader, l int) error {
+	var ms Int64
+	if err := ms.ReadFrom(r, l); err != nil {
+		return err
+	}
+	*t = UnixMillis(time.UnixMilli(int64(ms)).UTC())
+	return nil
+}
+
+func (t *UnixMillis) Val() time.Time {
+	return time.Time(*t)
+}
+
+// UnixNanos is a time.Time, and *UnixNanos implements Sophie interface with an
+// order-preserving encoding of the Unix nanoseconds (as an Int64). Only the
+// times between years 1678 and 2262 can be represented. The time read is in
+// UTC.
+type UnixNanos time.Time
+
+// Returns a new instance of *UnixNanos as a Sophier
+func NewUnixNanos() sophie.Sophier {
+	return new(UnixNanos)
+}
+
+// SophieWriter interface
+func (t UnixNanos) WriteTo(w sophie.Writer) error {
+	return Int64(time.Time(t).UnixNano()).WriteTo(w)
+}
+
+// Sizer interface
+func (UnixNanos) EncodedLen() int {
+	return 8
+}
+
+// SophieReader interface
+func (t *UnixNanos) ReadFrom(r sophie.Reader, l int) error {
+	var ns Int64
+	if err := ns.ReadFrom(r, l); err != nil {
+		return err
+	}
+	*t = UnixNanos(time.Unix(0, int64(ns)).UTC())
+	return nil
+}
+
+func (t *UnixNanos) Val() time.Time {
+	return time.Time(*t)
+}
+
+const (
+	escByte     = 0x00
+	escEscaped  = 0xFF
+	escTerminal = 0x01
+)
+
+func writeEscaped(w sophie.Writer, bs []byte) error {
+	for len(bs) > 0 {
+		p := 0
+		for p < len(bs) && bs[p] != escByte {
+			p++
+		}
+		if _, err := w.Write(bs[:p]); err != nil {
+			return errorsp.WithStacks(err)
+		}
+		if p == len(bs) {
+			break
+		}
+		if _, err := w.Write([]byte{escByte, escEscaped}); err != nil {
+			return errorsp.WithStacks(err)
+		}
+		bs = bs[p+1:]
+	}
+	_, err := w.Write([]byte{escByte, escTerminal})
+	return errorsp.WithStacks(err)
+}
+
+// readEscaped reads the bytes written by writeEscaped and appends them to
+// buf.
+func readEscaped(r sophie.Reader, buf []byte) ([]byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errorsp.Cause(err) == io.EOF {
+				return buf, errorsp.WithStacks(io.ErrUnexpectedEOF)
+			}
+			return buf, errorsp.WithStacks(err)
+		}
+		if b != escByte {
+			buf = append(buf, b)
+			continue
+		}
+		if b, err = r.ReadByte(); err != nil {
+			if errorsp.Cause(err) == io.EOF {
+				return buf, errorsp.WithStacks(io.ErrUnexpectedEOF)
+			}
+			return buf, errorsp.WithStacks(err)
+		}
+		switch b {
+		case escEscaped:
+			buf = append(buf, escByte)
+		case escTerminal:
+			return buf, nil
+		default:
+			return buf, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "unexpected byte %d after 0x00", b)
+		}
+	}
+}
+
+// *Bytes implements Sophie interface with an order-preserving encoding.
+type Bytes []byte
+
+// Returns a new instance of *Bytes as a Sophier
+func NewBytes() sophie.Sophier {
+	return new(Bytes)
+}
+
+// SophieWriter interface
+func (bs Bytes) WriteTo(w sophie.Writer) error {
+	return writeEscaped(w, bs)
+}
+
+// Sizer interface
+func (bs Bytes) EncodedLen() int {
+	return len(bs) + bytes.Count(bs, []byte{escByte}) + 2
+}
+
+// SophieReader interface
+func (bs *Bytes) ReadFrom(r sophie.Reader, l int) error {
+	buf, err := readEscaped(r, (*bs)[:0])
+	*bs = buf
+	return err
+}
+
+// *String implements Sophie interface with an order-preserving encoding.
+type String string
+
+// Returns a new instance of *String as a Sophier
+func NewString() sophie.Sophier {
+	return new(String)
+}
+
+// SophieWriter interface
+func (s String) WriteTo(w sophie.Writer) error {
+	return writeEscaped(w, []byte(s))
+}
+
+// Sizer interface
+func (s String) EncodedLen() int {
+	return len(s) + strings.Count(string(s), "\x00") + 2
+}
+
+// SophieReader interface
+func (s *String) ReadFrom(r sophie.Reader, l int) error {
+	buf, err := readEscaped(r, nil)
+	if err != nil {
+		return err
+	}
+	*s = String(buf)
+	return nil
+}
+
+func (s *String) String() string {
+	return string(*s)
+}
+func (s *String) Val() string {
+	return string(*s)
+}
+
+// invertedWriter writes all bytes inverted to the wrapped Writer.
+type invertedWriter struct {
+	sophie.Writer
+}
+
+// io.Writer interface
+func (w invertedWriter) Write(p []byte) (int, error) {
+	var arr [64]byte
+	n := 0
+	for n < len(p) {
+		m := copy(arr[:], p[n:])
+		for i := range arr[:m] {
+			arr[i] = ^arr[i]
+		}
+		if _, err := w.Writer.Write(arr[:m]); err != nil {
+			return n, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// io.ByteWriter interface
+func (w invertedWriter) WriteByte(c byte) error {
+	return w.Writer.WriteByte(^c)
+}
+
+// invertedReader reads bytes from the wrapped Reader and inverts them.
+type invertedReader struct {
+	sophie.Reader
+}
+
+// io.Reader interface
+func (r invertedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	for i := range p[:n] {
+		p[i] = ^p[i]
+	}
+	return n, err
+}
+
+// io.ByteReader interface
+func (r invertedReader) ReadByte() (byte, error) {
+	c, err := r.Reader.ReadByte()
+	return ^c, err
+}
+
+// Desc wraps a key and makes it sort in descending order. The wrapped key
+// must have a self-delimiting encoding, e.g. any key type in this package.
+type Desc struct {
+	Key sophie.Sophier
+}
+
+// NewDescF returns a factory func of *Desc with the Key created by newKey.
+func NewDescF(newKey func() sophie.Sophier) func() sophie.Sophier {
+	return func() sophie.Sophier {
+		return &Desc{Key: newKey()}
+	}
+}
+
+// SophieWriter interface
+func (d Desc) WriteTo(w sophie.Writer) error {
+	return d.Key.WriteTo(invertedWriter{w})
+}
+
+// Sizer interface
+func (d Desc) EncodedLen() int {
+	return sophie.EncodedLen(d.Key)
+}
+
+// SophieReader interface
+func (d *Desc) ReadFrom(r sophie.Reader, l int) error {
+	return d.Key.ReadFrom(invertedReader{r}, l)
+}
+
+// Tuple is a composite key of several keys. Tuples are ordered by the first
+// element, then the second one, etc. All elements must have self-delimiting
+// encodings.
+type Tuple []sophie.Sophier
+
+// NewTupleF returns a factory func of *Tuple with the elements created by
+// newElements.
+func NewTupleF(newElements ...func() sophie.Sophier) func() sophie.Sophier {
+	return func() sophie.Sophier {
+		t := make(Tuple, len(newElements))
+		for i, newElement := range newElements {
+			t[i] = newElement()
+		}
+		return &t
+	}
+}
+
+// SophieWriter interface
+func (t Tuple) WriteTo(w sophie.Writer) error {
+	for _, el := range t {
+		if err := el.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sizer interface
+func (t Tuple) EncodedLen() int {
+	n := 0
+	for _, el := range t {
+		n += sophie.EncodedLen(el)
+	}
+	return n
+}
+
+// SophieReader interface
+func (t *Tuple) ReadFrom(r sophie.Reader, l int) error {
+	for _, el := range *t {
+		if err := el.ReadFrom(r, sophie.UNKNOWN_LEN); err != nil {
+			return err
+		}
+	}
+	return nil
+}
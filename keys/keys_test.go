@@ -0,0 +1,132 @@
+package keys
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/sophie"
+)
+
+func encode(t *testing.T, s sophie.SophieWriter) []byte {
+	var buf bytesp.Slice
+	assert.NoError(t, s.WriteTo(&buf))
+	return buf
+}
+
+// checkOrder checks that the serialized bytes of values (in ascending order)
+// are strictly ascending and can be read back with newF.
+func checkOrder(t *testing.T, newF func() sophie.Sophier, values ...sophie.SophieWriter) {
+	var last []byte
+	for i, v := range values {
+		cur := encode(t, v)
+		if i > 0 && bytes.Compare(last, cur) >= 0 {
+			t.Errorf("encoding of %v(%q) is not larger than the previous one(%q)", v, cur, last)
+		}
+		last = cur
+
+		buf := bytesp.Slice(cur)
+		back := newF()
+		assert.NoError(t, back.ReadFrom(&buf, len(buf)))
+		assert.Equal(t, fmt.Sprintf("back of %v", v), encode(t, back), cur)
+		assert.Equal(t, fmt.Sprintf("len(buf) after reading %v", v), len(buf), 0)
+	}
+}
+
+func TestInts(t *testing.T) {
+	checkOrder(t, NewInt32, Int32(math.MinInt32), Int32(-1000), Int32(-1), Int32(0), Int32(1), Int32(256), Int32(math.MaxInt32))
+	checkOrder(t, NewInt64, Int64(math.MinInt64), Int64(-1), Int64(0), Int64(1), Int64(1<<40), Int64(math.MaxInt64))
+	checkOrder(t, NewUint32, Uint32(0), Uint32(1), Uint32(256), Uint32(math.MaxUint32))
+	checkOrder(t, NewUint64, Uint64(0), Uint64(255), Uint64(256), Uint64(math.MaxUint64))
+
+	var i Int64
+	buf := bytesp.Slice(encode(t, Int64(-5)))
+	assert.NoError(t, i.ReadFrom(&buf, 8))
+	assert.Equal(t, "i", i, Int64(-5))
+}
+
+func TestFloats(t *testing.T) {
+	checkOrder(t, NewFloat64, Float64(math.Inf(-1)), Float64(-1e10), Float64(-1), Float64(-1e-300),
+		Float64(math.Copysign(0, -1)), Float64(0), Float64(1e-300), Float64(0.5), Float64(1), Float64(math.Inf(1)))
+	checkOrder(t, NewFloat32, Float32(math.Inf(-1)), Float32(-2.5), Float32(0), Float32(2.5), Float32(math.Inf(1)))
+
+	var f Float64
+	buf := bytesp.Slice(encode(t, Float64(-2.75)))
+	assert.NoError(t, f.ReadFrom(&buf, -1))
+	assert.Equal(t, "f", f, Float64(-2.75))
+}
+
+func TestTimes(t *testing.T) {
+	t0 := time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := time.Date(2014, 5, 13, 16, 53, 20, 500000000, time.FixedZone("", 3600))
+	t2 := time.Date(2014, 5, 13, 16, 53, 21, 0, time.UTC)
+	checkOrder(t, NewUnixSeconds, UnixSeconds(t0), UnixSeconds(t1), UnixSeconds(t2))
+	checkOrder(t, NewUnixMillis, UnixMillis(t0), UnixMillis(t1), UnixMillis(t2))
+	checkOrder(t, NewUnixNanos, UnixNanos(t0), UnixNanos(t1), UnixNanos(t2))
+
+	var ms UnixMillis
+	buf := bytesp.Slice(encode(t, UnixMillis(t1)))
+	assert.NoError(t, ms.ReadFrom(&buf, 8))
+	assert.Equal(t, "ms", ms.Val(), t1.UTC())
+}
+
+func TestStrings(t *testing.T) {
+	checkOrder(t, NewString, String(""), String("\x00"), String("\x00\x00"), String("\x00\x01"), String("\x01"),
+		String("a"), String("a\x00"), String("aa"), String("b"), String("\xff"))
+	checkOrder(t, NewBytes, Bytes(""), Bytes("\x00"), Bytes("ab"), Bytes("b"))
+
+	var s String
+	buf := bytesp.Slice(encode(t, String("a\x00b")))
+	assert.NoError(t, s.ReadFrom(&buf, -1))
+	assert.Equal(t, "s", s, String("a\x00b"))
+
+	buf = bytesp.Slice("a\x00")
+	assert.Error(t, s.ReadFrom(&buf, -1))
+	buf = bytesp.Slice("a\x00\x02")
+	assert.Error(t, s.ReadFrom(&buf, -1))
+}
+
+func TestDesc(t *testing.T) {
+	newF := NewDescF(NewString)
+	checkOrder(t, newF, &Desc{ptrString("b")}, &Desc{ptrString("aa")}, &Desc{ptrString("a")}, &Desc{ptrString("")})
+	checkOrder(t, NewDescF(NewInt64), &Desc{ptrInt64(10)}, &Desc{ptrInt64(0)}, &Desc{ptrInt64(-10)})
+
+	d := newF().(*Desc)
+	buf := bytesp.Slice(encode(t, Desc{ptrString("hello")}))
+	assert.NoError(t, d.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "d.Key", d.Key, sophie.Sophier(ptrString("hello")))
+}
+
+func ptrString(s string) *String {
+	return (*String)(&s)
+}
+
+func ptrInt64(i int64) *Int64 {
+	return (*Int64)(&i)
+}
+
+func TestTuple(t *testing.T) {
+	newF := NewTupleF(NewString, NewDescF(NewInt64))
+	tuple := func(s string, i int64) *Tuple {
+		return &Tuple{ptrString(s), &Desc{ptrInt64(i)}}
+	}
+	checkOrder(t, newF, tuple("a", 10), tuple("a", 2), tuple("a", -3), tuple("ab", 100), tuple("b", 1))
+}
+
+func TestEncodedLen(t *testing.T) {
+	tm := time.Unix(1400000000, 5)
+	for _, sw := range []sophie.SophieWriter{
+		Int32(1), Int64(1), Uint32(1), Uint64(1), Float32(1), Float64(1),
+		UnixSeconds(tm), UnixMillis(tm), UnixNanos(tm),
+		Bytes(nil), Bytes("a\x00b\x00"), String("\x00"), String("abc"),
+		Desc{Key: ptrString("a\x00")},
+		Tuple{ptrString("ab"), ptrInt64(3), &Desc{Key: ptrInt64(4)}},
+	} {
+		assert.Equal(t, fmt.Sprintf("EncodedLen(%v)", sw), sw.(sophie.Sizer).EncodedLen(), len(encode(t, sw)))
+	}
+}
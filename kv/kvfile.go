@@ -4,6 +4,9 @@ stores key-value pairs.
 
 KVFile format:
   vint(key-len) key vint(val-len) val
+
+StreamWriter and StreamReader carry kv pairs in the same format between
+processes, e.g. over a net.Conn.
 */
 package kv
 
@@ -41,33 +44,72 @@ func (kvw *Writer) Close() error {
 	return kvw.writer.Close()
 }
 
-// sophie.CollectCloser interface
-func (kvw *Writer) Collect(key, val sophie.SophieWriter) error {
-	// write key
-	kvw.objBuf.Reset()
-	key.WriteTo(&kvw.objBuf)
-	if err := sophie.VInt(len(kvw.objBuf)).WriteTo(kvw.writer); err != nil {
+// countedWriter is a sophie.Writer counting the bytes written through it.
+type countedWriter struct {
+	sophie.Writer
+	N int
+}
+
+func (w *countedWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.N += n
+	return n, err
+}
+
+func (w *countedWriter) WriteByte(c byte) error {
+	if err := w.Writer.WriteByte(c); err != nil {
 		return err
 	}
-	if _, err := kvw.writer.Write([]byte(kvw.objBuf)); err != nil {
+	w.N++
+	return nil
+}
+
+// writeObj writes the length of obj followed by obj. If obj is a
+// sophie.Sizer, it is written directly, otherwise through objBuf. An error is
+// returned if the bytes written do not match the EncodedLen of the Sizer.
+func writeObj(w sophie.Writer, objBuf *bytesp.Slice, obj sophie.SophieWriter) error {
+	if sz, ok := obj.(sophie.Sizer); ok {
+		l := sz.EncodedLen()
+		if err := sophie.VInt(l).WriteTo(w); err != nil {
+			return err
+		}
+		cw := countedWriter{Writer: w}
+		if err := obj.WriteTo(&cw); err != nil {
+			return err
+		}
+		if cw.N != l {
+			return errorsp.NewWithStacks("%T wrote %d bytes, but its EncodedLen is %d", obj, cw.N, l)
+		}
+		return nil
+	}
+	objBuf.Reset()
+	if err := obj.WriteTo(objBuf); err != nil {
 		return err
 	}
-	// write val
-	kvw.objBuf.Reset()
-	val.WriteTo(&kvw.objBuf)
-	if err := sophie.VInt(len(kvw.objBuf)).WriteTo(kvw.writer); err != nil {
+	if err := sophie.VInt(len(*objBuf)).WriteTo(w); err != nil {
 		return err
 	}
-	if _, err := kvw.writer.Write([]byte(kvw.objBuf)); err != nil {
+	_, err := w.Write([]byte(*objBuf))
+	return errorsp.WithStacks(err)
+}
+
+// sophie.CollectCloser interface
+func (kvw *Writer) Collect(key, val sophie.SophieWriter) error {
+	if err := writeObj(kvw.writer, &kvw.objBuf, key); err != nil {
 		return err
 	}
-	// success
-	return nil
+	return writeObj(kvw.writer, &kvw.objBuf, val)
 }
 
 type countedReadCloser struct {
 	Pos int64
 	sophie.ReadCloser
+	arena *sophie.Arena
+}
+
+// sophie.Allocator interface
+func (r *countedReadCloser) Alloc(n int) []byte {
+	return sophie.ArenaReader{Arena: r.arena}.Alloc(n)
 }
 
 func (r *countedReadCloser) Read(p []byte) (n int, err error) {
@@ -86,11 +128,9 @@ func (r *countedReadCloser) ReadByte() (c byte, err error) {
 }
 
 func (r *countedReadCloser) Skip(n int64) (int64, error) {
-	if n1, err := r.ReadCloser.Skip(n); err != nil {
-		return n1, err
-	}
-	r.Pos += n
-	return n, nil
+	n1, err := r.ReadCloser.Skip(n)
+	r.Pos += n1
+	return n1, err
 }
 
 func countReadCloser(reader sophie.ReadCloser) *countedReadCloser {
@@ -120,39 +160,83 @@ func (kvr *Reader) Close() error {
 	return kvr.reader.Close()
 }
 
+// SetArena makes the bytes decoded by Next, e.g. sophie.ByteSlice and
+// sophie.RawByteSlice, allocated from the arena if it has enough space. The
+// arena is reset at the beginning of each call to Next, so the decoded bytes
+// are only valid until the next call. Passing nil turns it off.
+func (kvr *Reader) SetArena(arena *sophie.Arena) {
+	kvr.reader.arena = arena
+}
+
+// Pos returns the position in the file of the next pair to read, which can be
+// passed to Seek later, e.g. for an index of the pairs.
+func (kvr *Reader) Pos() int64 {
+	return kvr.reader.Pos
+}
+
+// io.Seeker interface. The position must be the start of a pair, e.g.
+// returned by Pos or ReadAsByteOffs. It is supported if the ReadCloser opened
+// by the FileSystem implements io.Seeker, e.g. that of sophie.LocalFS or
+// sophie.MemFS. Together with Pos it allows reading a byte range of a kv file,
+// e.g. a split of a large file.
+func (kvr *Reader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := kvr.reader.ReadCloser.(io.Seeker)
+	if !ok {
+		return 0, errorsp.NewWithStacks("%T does not support seeking", kvr.reader.ReadCloser)
+	}
+	if whence == io.SeekCurrent {
+		offset, whence = kvr.reader.Pos+offset, io.SeekStart
+	}
+	pos, err := seeker.Seek(offset, whence)
+	if err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	kvr.reader.Pos = pos
+	return pos, nil
+}
+
 // Next fetches next key/val pair
 func (kvr *Reader) Next(key, val sophie.SophieReader) error {
+	if kvr.reader.arena != nil {
+		kvr.reader.arena.Reset()
+	}
 	var l sophie.VInt
 	if err := (&l).ReadFrom(&kvr.reader, -1); err != nil {
 		return errorsp.WithStacksAndMessage(err, "reading key length failed")
 	}
+	if err := sophie.DecodeLimits.CheckLen(int(l)); err != nil {
+		return errorsp.WithStacksAndMessage(err, "bad key length at %d", kvr.reader.Pos)
+	}
 	posEnd := kvr.reader.Pos + int64(l)
 	if err := key.ReadFrom(&kvr.reader, int(l)); err != nil {
 		if errorsp.Cause(err) == io.EOF {
 			return errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "Unexpected EOF reading key")
 		}
-		return errorsp.WithStacksAndMessage(err, "reading key %v failed", key)
+		return errorsp.WithStacksAndMessage(err, "reading key %s failed", sophie.Text(key))
 	}
 	if kvr.reader.Pos != posEnd {
-		return errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "PosEnd wrong after reading key(len = %d) %v: exp %d, act %d", l, key, posEnd, kvr.reader.Pos)
+		return errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "PosEnd wrong after reading key(len = %d) %s: exp %d, act %d", l, sophie.Text(key), posEnd, kvr.reader.Pos)
 	}
 
 	if err := (&l).ReadFrom(&kvr.reader, -1); err != nil {
 		if errorsp.Cause(err) == io.EOF {
-			return errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "Unexpected EOF reading val length for key %v", key)
+			return errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "Unexpected EOF reading val length for key %s", sophie.Text(key))
 		}
 		return err
 	}
+	if err := sophie.DecodeLimits.CheckLen(int(l)); err != nil {
+		return errorsp.WithStacksAndMessage(err, "bad value length for key %s at %d", sophie.Text(key), kvr.reader.Pos)
+	}
 	posEnd = kvr.reader.Pos + int64(l)
 	if err := val.ReadFrom(&kvr.reader, int(l)); err != nil {
 		if errorsp.Cause(err) == io.EOF {
-			return errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "Unexpected EOF reading val for key %v", key)
+			return errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "Unexpected EOF reading val for key %s", sophie.Text(key))
 		}
-		return errorsp.WithStacksAndMessage(err, "reading value for key %v failed", key)
+		return errorsp.WithStacksAndMessage(err, "reading value for key %s failed", sophie.Text(key))
 	}
 	if kvr.reader.Pos != posEnd {
-		return errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "PosEnd wrong after reading key %v, value %v: exp %d, act %d",
-			key, val, posEnd, kvr.reader.Pos)
+		return errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "PosEnd wrong after reading key %s, value %s: exp %d, act %d",
+			sophie.Text(key), sophie.Text(val), posEnd, kvr.reader.Pos)
 	}
 	return nil
 }
@@ -160,30 +244,28 @@ func (kvr *Reader) Next(key, val sophie.SophieReader) error {
 // ReadAsByteOffs reads a kv file as a slice of buffer and some int slices
 // of key offsets, key ends, value offsets, and value ends.
 func ReadAsByteOffs(fp sophie.FsPath) (buffer bytesp.Slice, keyOffs, keyEnds, valOffs, valEnds villa.IntSlice, err error) {
-	fi, err := fp.Stat()
-	if err != nil {
-		return nil, nil, nil, nil, nil, errorsp.WithStacks(err)
-	}
-
 	reader, err := fp.Open()
 	if err != nil {
 		return nil, nil, nil, nil, nil, errorsp.WithStacks(err)
 	}
 	defer reader.Close()
 
-	buffer = make(bytesp.Slice, fi.Size())
-	if n, err := reader.Read(buffer); n != len(buffer) || err != nil {
-		if err != nil {
-			return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(err, "n = %d", n)
-		}
-		return nil, nil, nil, nil, nil, errorsp.NewWithStacks("Expected %d bytes, but only read %d bytes", len(buffer), n)
+	// Reads to EOF rather than by the size from Stat, which may be expensive,
+	// e.g. for a sophie.CompressedFS, and a single Read may return fewer bytes.
+	all, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(err, "n = %d", len(all))
 	}
+	buffer = all
 	buf := countReadCloser(bytesp.NewPSlice(buffer))
 	for buf.Pos < int64(len(buffer)) {
 		var l sophie.VInt
 		if err := (&l).ReadFrom(buf, -1); err != nil {
 			return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "failed to read key-lenth: %v", err)
 		}
+		if l < 0 || int64(l) > int64(len(buffer))-buf.Pos {
+			return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "bad key-length %d at %d", l, buf.Pos)
+		}
 		keyOffs = append(keyOffs, int(buf.Pos))
 		if _, err := buf.Skip(int64(l)); err != nil {
 			return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "failed to skip key: %v", err)
@@ -192,6 +274,9 @@ func ReadAsByteOffs(fp sophie.FsPath) (buffer bytesp.Slice, keyOffs, keyEnds, va
 		if err := (&l).ReadFrom(buf, -1); err != nil {
 			return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "failed to read value-lenth: %v", err)
 		}
+		if l < 0 || int64(l) > int64(len(buffer))-buf.Pos {
+			return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "bad value-length %d at %d", l, buf.Pos)
+		}
 		valOffs = append(valOffs, int(buf.Pos))
 		if _, err := buf.Skip(int64(l)); err != nil {
 			return nil, nil, nil, nil, nil, errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "failed to read value: %v", err)
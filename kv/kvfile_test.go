@@ -62,6 +62,62 @@ func TestReaderWriter(t *testing.T) {
 	assert.NoError(t, reader.Close())
 }
 
+// wrongSizer promotes EncodedLen from the embedded VInt, which does not match
+// its own WriteTo.
+type wrongSizer struct {
+	sophie.VInt
+}
+
+// sophie.SophieWriter interface
+func (wrongSizer) WriteTo(w sophie.Writer) error {
+	return sophie.String("abc").WriteTo(w)
+}
+
+func TestWriter_WrongSizer(t *testing.T) {
+	writer, err := NewWriter(sophie.FsPath{Fs: sophie.NewMemFS(), Path: "/wrong.kv"})
+	assert.NoErrorOrDie(t, err)
+	defer writer.Close()
+
+	assert.Error(t, writer.Collect(sophie.VInt(1), wrongSizer{}))
+}
+
+func TestWriter_UnregisteredUnion(t *testing.T) {
+	writer, err := NewWriter(sophie.FsPath{Fs: sophie.NewMemFS(), Path: "/union.kv"})
+	assert.NoErrorOrDie(t, err)
+	defer writer.Close()
+
+	reg := sophie.NewRegistry()
+	assert.NoError(t, reg.Register(1, sophie.NewString))
+	vi := sophie.VInt(5)
+	assert.Error(t, writer.Collect(sophie.VInt(1), sophie.Union{Registry: reg, Val: &vi}))
+}
+
+func TestWriter_NotSizer(t *testing.T) {
+	fn := sophie.LocalFsPath("./test_notsizer.kv")
+	defer villa.Path(fn.Path).Remove()
+
+	// Values not implementing sophie.Sizer are written through a buffer.
+	vals := []sophie.JSON[[]int]{{Val: []int{1}}, {Val: []int{2, 3}}}
+	writer, err := NewWriter(fn)
+	assert.NoErrorOrDie(t, err)
+	for i, val := range vals {
+		assert.NoError(t, writer.Collect(sophie.VInt(i), val))
+	}
+	assert.NoError(t, writer.Close())
+
+	reader, err := NewReader(fn)
+	assert.NoErrorOrDie(t, err)
+	defer reader.Close()
+	var key sophie.VInt
+	var val sophie.JSON[[]int]
+	for i := range vals {
+		assert.NoErrorOrDie(t, reader.Next(&key, &val))
+		assert.Equal(t, "key", key, sophie.VInt(i))
+		assert.Equal(t, "val", val, vals[i])
+	}
+	assert.Equal(t, "Next", errorsp.Cause(reader.Next(&key, &val)), io.EOF)
+}
+
 func TestReader_UnexpectedEOF(t *testing.T) {
 	test := func(n int64) {
 		fn := sophie.TempDirPath().Join("TestReader_UnexpectedEOF.kv")
@@ -162,3 +218,115 @@ func TestReadAsByteOffsWriteByteOffs(t *testing.T) {
 func TestWriteByteOffs_DiffLength(t *testing.T) {
 	assert.Error(t, WriteByteOffs(sophie.FsPath{}, nil, nil, []int{1}, []int{1, 2}, []int{1, 2, 3}))
 }
+
+func TestReader_BadLength(t *testing.T) {
+	fn := sophie.FsPath{Fs: sophie.NewMemFS(), Path: "TestReader_BadLength.kv"}
+
+	w, err := fn.Create()
+	assert.NoErrorOrDie(t, err)
+	// A corrupted key length of about 1<<60
+	_, err = w.Write([]byte("\xff\xff\xff\xff\xff\xff\xff\xff\x0fabc"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	reader, err := NewReader(fn)
+	assert.NoErrorOrDie(t, err)
+	defer reader.Close()
+
+	var key, val sophie.RawByteSlice
+	err = reader.Next(&key, &val)
+	if !assert.Equal(t, "err", errorsp.Cause(err), sophie.ErrBadFormat) {
+		t.Logf("err: %v", err)
+	}
+}
+
+func TestReader_Arena(t *testing.T) {
+	fn := sophie.FsPath{Fs: sophie.NewMemFS(), Path: "TestReader_Arena.kv"}
+
+	writer, err := NewWriter(fn)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, writer.Collect(sophie.ByteSlice("key1"), sophie.ByteSlice("val1")))
+	assert.NoError(t, writer.Collect(sophie.ByteSlice("key2"), sophie.ByteSlice("val2")))
+	assert.NoError(t, writer.Close())
+
+	reader, err := NewReader(fn)
+	assert.NoErrorOrDie(t, err)
+	defer reader.Close()
+
+	buf := make([]byte, 100)
+	reader.SetArena(sophie.NewArena(buf))
+	var key, val sophie.ByteSlice
+	for i := 1; i <= 2; i++ {
+		assert.NoError(t, reader.Next(&key, &val))
+		assert.Equal(t, "key", string(key), fmt.Sprintf("key%d", i))
+		assert.Equal(t, "val", string(val), fmt.Sprintf("val%d", i))
+		// The arena is reset for each Next
+		assert.Equal(t, "&key[0]", &key[0], &buf[0])
+		assert.Equal(t, "&val[0]", &val[0], &buf[4])
+	}
+	assert.Equal(t, "Next", errorsp.Cause(reader.Next(&key, &val)), io.EOF)
+}
+
+func TestReader_ArenaFull(t *testing.T) {
+	fn := sophie.FsPath{Fs: sophie.NewMemFS(), Path: "TestReader_ArenaFull.kv"}
+	writer, err := NewWriter(fn)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, writer.Collect(sophie.ByteSlice("abcd"), sophie.ByteSlice("efgh")))
+	assert.NoError(t, writer.Collect(sophie.ByteSlice("1234567"), sophie.ByteSlice("XY")))
+	assert.NoError(t, writer.Close())
+
+	reader, err := NewReader(fn)
+	assert.NoErrorOrDie(t, err)
+	defer reader.Close()
+	reader.SetArena(sophie.NewArena(make([]byte, 8)))
+
+	var key, val sophie.ByteSlice
+	assert.NoError(t, reader.Next(&key, &val))
+	assert.NoError(t, reader.Next(&key, &val))
+	assert.Equal(t, "key", string(key), "1234567")
+	assert.Equal(t, "val", string(val), "XY")
+}
+
+func TestReader_Seek(t *testing.T) {
+	for _, fn := range []sophie.FsPath{
+		sophie.TempDirPath().Join("TestReader_Seek.kv"),
+		{Fs: sophie.NewMemFS(), Path: "TestReader_Seek.kv"},
+	} {
+		writer, err := NewWriter(fn)
+		assert.NoErrorOrDie(t, err)
+		for i := 0; i < 1000; i++ {
+			assert.NoError(t, writer.Collect(sophie.VInt(i), sophie.String(strconv.Itoa(i))))
+		}
+		assert.NoError(t, writer.Close())
+
+		reader, err := NewReader(fn)
+		assert.NoErrorOrDie(t, err)
+		// An index of the positions of the pairs.
+		var poses []int64
+		var key sophie.VInt
+		var val sophie.String
+		for {
+			pos := reader.Pos()
+			if err := reader.Next(&key, &val); err != nil {
+				assert.Equal(t, "err", errorsp.Cause(err), io.EOF)
+				break
+			}
+			poses = append(poses, pos)
+		}
+		assert.Equal(t, "len(poses)", len(poses), 1000)
+
+		for _, i := range []int{500, 3, 999} {
+			pos, err := reader.Seek(poses[i], io.SeekStart)
+			assert.NoError(t, err)
+			assert.Equal(t, "pos", pos, poses[i])
+			assert.NoError(t, reader.Next(&key, &val))
+			assert.Equal(t, "key", key, sophie.VInt(i))
+			assert.Equal(t, "val", val, sophie.String(strconv.Itoa(i)))
+			if i+1 < len(poses) {
+				assert.Equal(t, "Pos", reader.Pos(), poses[i+1])
+			}
+		}
+		assert.NoError(t, reader.Close())
+		assert.NoError(t, fn.Remove())
+	}
+}
@@ -2,6 +2,7 @@ package kv
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/golangplus/errors"
 
@@ -9,13 +10,29 @@ import (
 )
 
 /*
-	A folder with KV Files as an mr.Input
+	A folder with KV Files as an mr.Input. Temporary files, i.e. parts not
+	published yet by DirOutput, are skipped.
 */
 type DirInput sophie.FsPath
 
+// parts returns the FileInfos of the part files.
+func (in DirInput) parts() ([]os.FileInfo, error) {
+	infos, err := in.Fs.ReadDir(in.Path)
+	if err != nil {
+		return nil, err
+	}
+	parts := infos[:0]
+	for _, info := range infos {
+		if !sophie.IsTempFile(info.Name()) {
+			parts = append(parts, info)
+		}
+	}
+	return parts, nil
+}
+
 // mr.Input interface
 func (in DirInput) PartCount() (int, error) {
-	infos, err := in.Fs.ReadDir(in.Path)
+	infos, err := in.parts()
 	if err != nil {
 		return 0, errorsp.WithStacks(err)
 	}
@@ -25,7 +42,7 @@ func (in DirInput) PartCount() (int, error) {
 
 // mr.Input interface
 func (in DirInput) Iterator(index int) (sophie.IterateCloser, error) {
-	infos, err := in.Fs.ReadDir(in.Path)
+	infos, err := in.parts()
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +51,8 @@ func (in DirInput) Iterator(index int) (sophie.IterateCloser, error) {
 }
 
 /*
-	A folder with KV Files as an Output
+	A folder with KV Files as an Output. Each part is written to a temporary
+	file, and published by Close only if it is completely written.
 */
 type DirOutput sophie.FsPath
 
@@ -43,7 +61,11 @@ func (out DirOutput) Collector(index int) (sophie.CollectCloser, error) {
 	if err := out.Fs.Mkdir(out.Path, 0755); err != nil {
 		return nil, errorsp.WithStacks(err)
 	}
-	return NewWriter(sophie.FsPath(out).Join(fmt.Sprintf("part-%05d", index)))
+	writer, err := sophie.FsPath(out).Join(fmt.Sprintf("part-%05d", index)).CreateAtomic()
+	if err != nil {
+		return nil, errorsp.WithStacks(err)
+	}
+	return &Writer{writer: writer}, nil
 }
 
 // Clean removes the folder.
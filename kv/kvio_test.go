@@ -0,0 +1,104 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/go-villa"
+	"github.com/daviddengcn/sophie"
+)
+
+func TestDirOutputInput(t *testing.T) {
+	dir := sophie.FsPath{Fs: sophie.NewMemFS(), Path: "/out"}
+	out := DirOutput(dir)
+	c0, err := out.Collector(0)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, c0.Collect(sophie.String("a"), sophie.VInt(1)))
+	assert.NoError(t, c0.Close())
+
+	// A part being written is not visible.
+	c1, err := out.Collector(1)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, c1.Collect(sophie.String("b"), sophie.VInt(2)))
+
+	in := DirInput(dir)
+	n, err := in.PartCount()
+	assert.NoError(t, err)
+	assert.Equal(t, "n", n, 1)
+	it, err := in.Iterator(0)
+	assert.NoErrorOrDie(t, err)
+	var key sophie.String
+	var val sophie.VInt
+	assert.NoError(t, it.Next(&key, &val))
+	assert.Equal(t, "key", key, sophie.String("a"))
+	assert.NoError(t, it.Close())
+
+	assert.NoError(t, c1.Close())
+	n, err = in.PartCount()
+	assert.NoError(t, err)
+	assert.Equal(t, "n", n, 2)
+}
+
+func TestDirOutputInput_Compressed(t *testing.T) {
+	dir := sophie.FsPath{Fs: sophie.CompressedFS{FileSystem: sophie.NewMemFS(), Default: sophie.Gzip}, Path: "/out"}
+	c, err := DirOutput(dir).Collector(0)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, c.Collect(sophie.String("a"), sophie.VInt(1)))
+	assert.NoError(t, c.Collect(sophie.String("b"), sophie.VInt(2)))
+	assert.NoError(t, c.Close())
+
+	it, err := DirInput(dir).Iterator(0)
+	assert.NoErrorOrDie(t, err)
+	var key sophie.String
+	var val sophie.VInt
+	assert.NoError(t, it.Next(&key, &val))
+	assert.NoError(t, it.Next(&key, &val))
+	assert.Equal(t, "key", key, sophie.String("b"))
+	assert.Equal(t, "val", val, sophie.VInt(2))
+	assert.NoError(t, it.Close())
+
+	buffer, keyOffs, _, _, _, err := ReadAsByteOffs(dir.Join("part-00000"))
+	assert.NoError(t, err)
+	assert.Equal(t, "len(buffer)", len(buffer), 10)
+	assert.Equal(t, "keyOffs", keyOffs, villa.IntSlice{1, 6})
+}
+
+func TestDirInput_IOFS(t *testing.T) {
+	mem := sophie.NewMemFS()
+	c, err := DirOutput(sophie.FsPath{Fs: mem, Path: "/data"}).Collector(0)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, c.Collect(sophie.String("a"), sophie.VInt(1)))
+	assert.NoError(t, c.Close())
+
+	// Reads the kv files through io/fs.
+	in := DirInput(sophie.FsPath{Fs: sophie.FromIOFS(sophie.ToIOFS(mem)), Path: "data"})
+	n, err := in.PartCount()
+	assert.NoError(t, err)
+	assert.Equal(t, "n", n, 1)
+	it, err := in.Iterator(0)
+	assert.NoErrorOrDie(t, err)
+	var key sophie.String
+	var val sophie.VInt
+	assert.NoError(t, it.Next(&key, &val))
+	assert.Equal(t, "key", key, sophie.String("a"))
+	assert.Equal(t, "val", val, sophie.VInt(1))
+	assert.NoError(t, it.Close())
+}
+
+func TestReadAsByteOffs_Compressed(t *testing.T) {
+	fn := sophie.FsPath{Fs: sophie.CompressedFS{FileSystem: sophie.NewMemFS()}, Path: "/big.kv.gz"}
+	w, err := NewWriter(fn)
+	assert.NoErrorOrDie(t, err)
+	// Much larger than the buffer of the decompressing reader.
+	const n = 100000
+	for i := 0; i < n; i++ {
+		assert.NoError(t, w.Collect(sophie.VInt(i), sophie.String("value")))
+	}
+	assert.NoError(t, w.Close())
+
+	buffer, keyOffs, _, _, valEnds, err := ReadAsByteOffs(fn)
+	assert.NoErrorOrDie(t, err)
+	assert.Equal(t, "len(keyOffs)", len(keyOffs), n)
+	assert.Equal(t, "last valEnd", valEnds[n-1], len(buffer))
+}
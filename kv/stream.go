@@ -0,0 +1,272 @@
+package kv
+
+import (
+	"io"
+	"net"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/sophie"
+)
+
+/*
+A stream carries kv pairs between processes, e.g. over a net.Conn, as a
+sequence of frames:
+
+	type-byte vint(payload-len) payload
+
+A data frame contains kv pairs in the kv file format. A stream is terminated
+by an end frame, or by an error frame whose payload is the error message.
+*/
+const (
+	frameData  byte = 1
+	frameEnd   byte = 2
+	frameError byte = 3
+)
+
+// DefaultStreamBatchSize is the default BatchSize of a StreamWriter.
+const DefaultStreamBatchSize = 64 << 10
+
+// StreamError is returned by StreamReader.Next if the writer aborted the
+// stream.
+type StreamError struct {
+	// The message of the error passed to StreamWriter.Abort.
+	Message string
+}
+
+// error interface
+func (e *StreamError) Error() string {
+	return "stream aborted: " + e.Message
+}
+
+// StreamWriter writes kv pairs to a stream read by a StreamReader. Pairs are
+// buffered and sent as a data frame once BatchSize bytes are collected, or on
+// Flush. *StreamWriter implements the sophie.CollectCloser interface.
+type StreamWriter struct {
+	// The number of bytes of pairs buffered before they are sent.
+	BatchSize int
+
+	w      io.Writer
+	batch  bytesp.Slice
+	objBuf bytesp.Slice
+	hdr    bytesp.Slice
+	err    error
+}
+
+// NewStreamWriter returns a *StreamWriter writing frames to w with
+// DefaultStreamBatchSize. If w has a Flush method, e.g. a *bufio.Writer, it
+// is called after frames are written.
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{
+		BatchSize: DefaultStreamBatchSize,
+		w:         w,
+	}
+}
+
+// sophie.Collector interface
+func (sw *StreamWriter) Collect(key, val sophie.SophieWriter) error {
+	if sw.err != nil {
+		return sw.err
+	}
+	start := len(sw.batch)
+	if err := writeObj(&sw.batch, &sw.objBuf, key); err != nil {
+		sw.batch = sw.batch[:start]
+		return err
+	}
+	if err := writeObj(&sw.batch, &sw.objBuf, val); err != nil {
+		sw.batch = sw.batch[:start]
+		return err
+	}
+	if len(sw.batch) >= sw.BatchSize {
+		return sw.Flush()
+	}
+	return nil
+}
+
+func (sw *StreamWriter) writeFrame(tp byte, payload []byte) error {
+	sw.hdr = append(sw.hdr[:0], tp)
+	if err := sophie.VInt(len(payload)).WriteTo(&sw.hdr); err != nil {
+		return err
+	}
+	bufs := net.Buffers{sw.hdr}
+	if len(payload) > 0 {
+		// Writing empty bytes blocks on some connections, e.g. net.Pipe.
+		bufs = append(bufs, payload)
+	}
+	if _, err := bufs.WriteTo(sw.w); err != nil {
+		sw.err = errorsp.WithStacks(err)
+		return sw.err
+	}
+	return nil
+}
+
+// sendBatch sends the buffered pairs as a data frame, if any.
+func (sw *StreamWriter) sendBatch() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if len(sw.batch) == 0 {
+		return nil
+	}
+	if err := sw.writeFrame(frameData, sw.batch); err != nil {
+		return err
+	}
+	sw.batch.Reset()
+	return nil
+}
+
+// flushWriter calls the Flush method of the underlying io.Writer, if any.
+func (sw *StreamWriter) flushWriter() error {
+	f, ok := sw.w.(interface {
+		Flush() error
+	})
+	if !ok {
+		return nil
+	}
+	if err := f.Flush(); err != nil {
+		sw.err = errorsp.WithStacks(err)
+		return sw.err
+	}
+	return nil
+}
+
+// Flush sends the buffered pairs, if any.
+func (sw *StreamWriter) Flush() error {
+	if err := sw.sendBatch(); err != nil {
+		return err
+	}
+	return sw.flushWriter()
+}
+
+// finish sends the buffered pairs and the last frame. The StreamWriter cannot
+// be used afterwards.
+func (sw *StreamWriter) finish(tp byte, payload []byte) error {
+	if err := sw.sendBatch(); err != nil {
+		return err
+	}
+	if err := sw.writeFrame(tp, payload); err != nil {
+		return err
+	}
+	if err := sw.flushWriter(); err != nil {
+		return err
+	}
+	sw.err = errorsp.NewWithStacks("the stream has been finished")
+	return nil
+}
+
+// io.Closer interface. It sends the buffered pairs and an end frame. The
+// underlying io.Writer is not closed.
+func (sw *StreamWriter) Close() error {
+	return sw.finish(frameEnd, nil)
+}
+
+// Abort sends the buffered pairs and an error frame with the message of err,
+// which is returned to the reader as a *StreamError. The underlying io.Writer
+// is not closed.
+func (sw *StreamWriter) Abort(err error) error {
+	return sw.finish(frameError, []byte(err.Error()))
+}
+
+type readNopCloser struct {
+	sophie.Reader
+	sophie.EmptyClose
+}
+
+// StreamReader reads kv pairs from a stream written by a StreamWriter.
+// *StreamReader implements the sophie.IterateCloser interface.
+type StreamReader struct {
+	pairs Reader
+	// The position of the end of the current data frame.
+	frameEnd int64
+	err      error
+}
+
+// NewStreamReader returns a *StreamReader reading frames from r.
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{
+		pairs: Reader{
+			reader: countedReadCloser{ReadCloser: readNopCloser{Reader: sophie.NewReader(r)}},
+		},
+	}
+}
+
+// io.Closer interface. The underlying io.Reader is not closed.
+func (sr *StreamReader) Close() error {
+	return nil
+}
+
+// SetArena works as Reader.SetArena.
+func (sr *StreamReader) SetArena(arena *sophie.Arena) {
+	sr.pairs.SetArena(arena)
+}
+
+// readFrame reads the header of the next frame, and the payload if it is not
+// a data frame.
+func (sr *StreamReader) readFrame() error {
+	reader := &sr.pairs.reader
+	tp, err := reader.ReadByte()
+	if err != nil {
+		if errorsp.Cause(err) == io.EOF {
+			return errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "stream ended without an end frame")
+		}
+		return errorsp.WithStacks(err)
+	}
+	var l sophie.VInt
+	if err := l.ReadFrom(reader, sophie.UNKNOWN_LEN); err != nil {
+		if errorsp.Cause(err) == io.EOF {
+			return errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "reading frame length failed")
+		}
+		return err
+	}
+	if l < 0 {
+		return errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "bad frame length %d at %d", l, reader.Pos)
+	}
+	switch tp {
+	case frameData:
+		sr.frameEnd = reader.Pos + int64(l)
+		return nil
+	case frameEnd:
+		if _, err := reader.Skip(int64(l)); err != nil {
+			return errorsp.WithStacks(err)
+		}
+		return io.EOF
+	case frameError:
+		if err := sophie.DecodeLimits.CheckLen(int(l)); err != nil {
+			return err
+		}
+		var msg sophie.RawString
+		if err := msg.ReadFrom(reader, int(l)); err != nil {
+			return err
+		}
+		return &StreamError{Message: string(msg)}
+	}
+	return errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "unknown frame type %d at %d", tp, reader.Pos-1)
+}
+
+// sophie.Iterator interface. It returns io.EOF at the end frame, or a
+// *StreamError at an error frame. Any error is returned by later calls.
+func (sr *StreamReader) Next(key, val sophie.SophieReader) error {
+	if sr.err != nil {
+		return sr.err
+	}
+	for sr.pairs.reader.Pos >= sr.frameEnd {
+		if err := sr.readFrame(); err != nil {
+			sr.err = err
+			return err
+		}
+	}
+	if err := sr.pairs.Next(key, val); err != nil {
+		if errorsp.Cause(err) == io.EOF {
+			// The stream ended inside a data frame.
+			err = errorsp.WithStacksAndMessage(io.ErrUnexpectedEOF, "stream ended at %d inside a frame ending at %d", sr.pairs.reader.Pos, sr.frameEnd)
+		}
+		sr.err = err
+		return err
+	}
+	if sr.pairs.reader.Pos > sr.frameEnd {
+		sr.err = errorsp.WithStacksAndMessage(sophie.ErrBadFormat, "pair crosses the end of the frame at %d", sr.frameEnd)
+		return sr.err
+	}
+	return nil
+}
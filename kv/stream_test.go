@@ -0,0 +1,130 @@
+package kv
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/sophie"
+)
+
+func TestStream(t *testing.T) {
+	const n = 1000
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		w := NewStreamWriter(c1)
+		w.BatchSize = 100
+		for i := 0; i < n; i++ {
+			if err := w.Collect(sophie.VInt(i), sophie.String("val")); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- w.Close()
+	}()
+
+	r := NewStreamReader(c2)
+	var key sophie.VInt
+	var val sophie.String
+	for i := 0; i < n; i++ {
+		assert.NoErrorOrDie(t, r.Next(&key, &val))
+		assert.Equal(t, "key", key, sophie.VInt(i))
+		assert.Equal(t, "val", val, sophie.String("val"))
+	}
+	assert.Equal(t, "err", r.Next(&key, &val), io.EOF)
+	assert.Equal(t, "err", r.Next(&key, &val), io.EOF)
+	assert.NoError(t, <-done)
+	assert.NoError(t, r.Close())
+}
+
+func TestStream_Abort(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		bw := bufio.NewWriter(c1)
+		w := NewStreamWriter(bw)
+		if err := w.Collect(sophie.String("key"), sophie.VInt(1)); err != nil {
+			done <- err
+			return
+		}
+		err := w.Abort(errors.New("failed"))
+		if err == nil && w.Collect(sophie.String("key"), sophie.VInt(2)) == nil {
+			err = errors.New("Collect after Abort should fail")
+		}
+		done <- err
+	}()
+
+	r := NewStreamReader(c2)
+	var key sophie.String
+	var val sophie.VInt
+	assert.NoErrorOrDie(t, r.Next(&key, &val))
+	assert.Equal(t, "key", key, sophie.String("key"))
+	assert.Equal(t, "val", val, sophie.VInt(1))
+
+	err := r.Next(&key, &val)
+	se, ok := err.(*StreamError)
+	assert.True(t, "ok", ok)
+	if ok {
+		assert.Equal(t, "Message", se.Message, "failed")
+	}
+	assert.NoError(t, <-done)
+}
+
+func TestStream_Flush(t *testing.T) {
+	var buf bytesp.Slice
+	w := NewStreamWriter(&buf)
+	assert.NoError(t, w.Collect(sophie.VInt(1), sophie.VInt(2)))
+	assert.Equal(t, "len(buf)", len(buf), 0)
+	assert.NoError(t, w.Flush())
+	// frame type, payload length, 2 pairs of length and value
+	assert.Equal(t, "buf", buf, bytesp.Slice("\x01\x04\x01\x01\x01\x02"))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "buf", buf, bytesp.Slice("\x01\x04\x01\x01\x01\x02\x02\x00"))
+
+	r := NewStreamReader(&buf)
+	var key, val sophie.VInt
+	assert.NoError(t, r.Next(&key, &val))
+	assert.Equal(t, "key", key, sophie.VInt(1))
+	assert.Equal(t, "val", val, sophie.VInt(2))
+	assert.Equal(t, "err", r.Next(&key, &val), io.EOF)
+}
+
+func TestStream_BadFormat(t *testing.T) {
+	var key, val sophie.VInt
+	for _, c := range []struct {
+		data string
+		err  error
+	}{
+		// No end frame
+		{"\x01\x04\x01\x01\x01\x02", io.ErrUnexpectedEOF},
+		// Truncated frame
+		{"\x01\x04\x01\x01", io.ErrUnexpectedEOF},
+		// Truncated frame after a complete pair
+		{"\x01\x08\x01\x01\x01\x02", io.ErrUnexpectedEOF},
+		// Unknown frame type
+		{"\x09\x00", sophie.ErrBadFormat},
+		// A pair crossing the frame
+		{"\x01\x02\x01\x01\x01\x02\x02\x00", sophie.ErrBadFormat},
+	} {
+		buf := bytesp.Slice(c.data)
+		r := NewStreamReader(&buf)
+		var err error
+		for err == nil {
+			err = r.Next(&key, &val)
+		}
+		assert.Equal(t, "err of "+c.data, errorsp.Cause(err), c.err)
+	}
+}
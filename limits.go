@@ -0,0 +1,62 @@
+package sophie
+
+import (
+	"slices"
+
+	"github.com/golangplus/errors"
+)
+
+// Limits restricts the sizes decoded from serialized data, so that a
+// corrupted or hostile input returns ErrBadFormat instead of making the
+// decoders allocate huge memory.
+type Limits struct {
+	// The maximum number of bytes of a single element, e.g. a ByteSlice, a
+	// String, or a key/value in a kv file.
+	MaxLen int
+	// The maximum number of elements of a slice or a map, e.g. the strings
+	// read by ReadStringSlice.
+	MaxCount int
+}
+
+// DecodeLimits is the Limits checked by the decoders in sophie and its sub
+// packages. Change it before any decoding if larger data is expected.
+var DecodeLimits = Limits{
+	MaxLen:   64 << 20,
+	MaxCount: 16 << 20,
+}
+
+// CheckLen returns ErrBadFormat if n is negative or larger than MaxLen.
+func (lim Limits) CheckLen(n int) error {
+	if n < 0 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "negative length %d", n)
+	}
+	if n > lim.MaxLen {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "length %d exceeds the limit %d", n, lim.MaxLen)
+	}
+	return nil
+}
+
+// CheckCount returns ErrBadFormat if n is negative or larger than MaxCount.
+func (lim Limits) CheckCount(n int) error {
+	if n < 0 {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "negative count %d", n)
+	}
+	if n > lim.MaxCount {
+		return errorsp.WithStacksAndMessage(ErrBadFormat, "count %d exceeds the limit %d", n, lim.MaxCount)
+	}
+	return nil
+}
+
+// maxPrealloc is the maximum number of elements preallocated for a decoded
+// slice or map. Since a count passing CheckCount may still be much larger
+// than the data following it, longer ones grow as the elements are read.
+const maxPrealloc = 1024
+
+// prealloc returns s[:0] with the capacity of at least min(n, maxPrealloc),
+// reusing the capacity, and the elements in it, of s if enough.
+func prealloc[S ~[]E, E any](s S, n int) S {
+	if c := min(n, maxPrealloc); cap(s) < c {
+		return slices.Grow(s[:cap(s)], c-cap(s))[:0]
+	}
+	return s[:0]
+}
@@ -0,0 +1,89 @@
+package sophie
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestLimits(t *testing.T) {
+	lim := Limits{MaxLen: 10, MaxCount: 3}
+	assert.NoError(t, lim.CheckLen(10))
+	assert.Equal(t, "CheckLen(11)", errorsp.Cause(lim.CheckLen(11)), ErrBadFormat)
+	assert.Equal(t, "CheckLen(-1)", errorsp.Cause(lim.CheckLen(-1)), ErrBadFormat)
+	assert.NoError(t, lim.CheckCount(3))
+	assert.Equal(t, "CheckCount(4)", errorsp.Cause(lim.CheckCount(4)), ErrBadFormat)
+	assert.Equal(t, "CheckCount(-1)", errorsp.Cause(lim.CheckCount(-1)), ErrBadFormat)
+}
+
+func withLimits(lim Limits, f func()) {
+	saved := DecodeLimits
+	defer func() {
+		DecodeLimits = saved
+	}()
+	DecodeLimits = lim
+	f()
+}
+
+func TestByteSlice_Limits(t *testing.T) {
+	// A corrupted length of about 1<<60
+	bs := bytesp.Slice("\xff\xff\xff\xff\xff\xff\xff\xff\x0f")
+	var ba ByteSlice
+	assert.Equal(t, "ba.ReadFrom", errorsp.Cause(ba.ReadFrom(&bs, -1)), ErrBadFormat)
+
+	// A negative length
+	bs = bytesp.Slice("\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01")
+	var s String
+	assert.Equal(t, "s.ReadFrom", errorsp.Cause(s.ReadFrom(&bs, -1)), ErrBadFormat)
+
+	// Length exceeds l
+	bs = bytesp.Slice("\x05abcde")
+	assert.Equal(t, "ba.ReadFrom(&bs, 3)", errorsp.Cause(ba.ReadFrom(&bs, 3)), ErrBadFormat)
+
+	withLimits(Limits{MaxLen: 4, MaxCount: 2}, func() {
+		bs = bytesp.Slice("\x05abcde")
+		assert.Equal(t, "ba.ReadFrom", errorsp.Cause(ba.ReadFrom(&bs, -1)), ErrBadFormat)
+		bs = bytesp.Slice("\x04abcd")
+		assert.NoError(t, ba.ReadFrom(&bs, -1))
+
+		bs = bytesp.Slice("abcde")
+		var rba RawByteSlice
+		assert.Equal(t, "rba.ReadFrom", errorsp.Cause(rba.ReadFrom(&bs, 5)), ErrBadFormat)
+
+		var sl []string
+		bs = bytesp.Slice("\x03\x00\x00\x00")
+		assert.Equal(t, "ReadStringSlice", errorsp.Cause(ReadStringSlice(&bs, &sl)), ErrBadFormat)
+
+		var vs Slice[VInt, *VInt]
+		bs = bytesp.Slice("\x03\x00\x00\x00")
+		assert.Equal(t, "vs.ReadFrom", errorsp.Cause(vs.ReadFrom(&bs, -1)), ErrBadFormat)
+	})
+}
+
+func TestLimits_Prealloc(t *testing.T) {
+	// A count of MaxCount followed by a few bytes.
+	count := "\x80\x80\x80\x08"
+	for _, c := range []struct {
+		name string
+		data string
+		read func(r Reader) error
+	}{
+		{"DeltaVIntSlice", count + "\x00", func(r Reader) error { return new(DeltaVIntSlice).ReadFrom(r, UNKNOWN_LEN) }},
+		{"PackedIntSlice", count + "\x00\x00", func(r Reader) error { return new(PackedIntSlice).ReadFrom(r, UNKNOWN_LEN) }},
+		{"PackedIntSlice(1 bit)", count + "\x00\x01\xff", func(r Reader) error { return new(PackedIntSlice).ReadFrom(r, UNKNOWN_LEN) }},
+		{"Bitset", count, func(r Reader) error { return new(Bitset).ReadFrom(r, UNKNOWN_LEN) }},
+		{"Slice", count + "\x00", func(r Reader) error { return new(Slice[VInt, *VInt]).ReadFrom(r, UNKNOWN_LEN) }},
+		{"Map", count + "\x00\x00", func(r Reader) error { return new(Map[VInt, VInt, *VInt, *VInt]).ReadFrom(r, UNKNOWN_LEN) }},
+		{"ReadStringSlice", count + "\x00", func(r Reader) error { return ReadStringSlice(r, new([]string)) }},
+	} {
+		bs := bytesp.Slice(c.data)
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		assert.Error(t, c.read(&bs))
+		runtime.ReadMemStats(&after)
+		assert.True(t, c.name+": allocated < 1MB", after.TotalAlloc-before.TotalAlloc < 1<<20)
+	}
+}
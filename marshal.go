@@ -0,0 +1,122 @@
+package sophie
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/golangplus/errors"
+)
+
+// *Binary implements Sophier interface for a type implementing
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler. It is serialized as
+// a ByteSlice of the marshaled bytes. PT is always *T, which can be inferred
+// in NewBinary, e.g. NewBinary[url.URL].
+type Binary[T any, PT interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}] struct {
+	Val T
+}
+
+// Returns a new instance of *Binary as a Sophier
+func NewBinary[T any, PT interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}]() Sophier {
+	return new(Binary[T, PT])
+}
+
+// SophieWriter interface
+func (b Binary[T, PT]) WriteTo(w Writer) error {
+	bs, err := PT(&b.Val).MarshalBinary()
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return ByteSlice(bs).WriteTo(w)
+}
+
+// SophieReader interface
+func (b *Binary[T, PT]) ReadFrom(r Reader, l int) error {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	if err := (*ByteSlice)(buf).readFrom(r, l); err != nil {
+		return err
+	}
+	return errorsp.WithStacks(PT(&b.Val).UnmarshalBinary(*buf))
+}
+
+// *Gob implements Sophier interface for any gob-encodable type. It is
+// serialized as a ByteSlice of the gob encoded bytes. Since each value is
+// encoded by a new gob.Encoder, the type information is repeated in every
+// value, which makes it much larger than the other encodings.
+//
+// The value is reset to the zero value before decoding, so fields that are
+// not in the data are not kept from the previous read.
+type Gob[T any] struct {
+	Val T
+}
+
+// Returns a new instance of *Gob as a Sophier
+func NewGob[T any]() Sophier {
+	return new(Gob[T])
+}
+
+// SophieWriter interface
+func (g Gob[T]) WriteTo(w Writer) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&g.Val); err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return ByteSlice(buf.Bytes()).WriteTo(w)
+}
+
+// SophieReader interface
+func (g *Gob[T]) ReadFrom(r Reader, l int) error {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	if err := (*ByteSlice)(buf).readFrom(r, l); err != nil {
+		return err
+	}
+	var zero T
+	g.Val = zero
+	return errorsp.WithStacks(gob.NewDecoder(bytes.NewReader(*buf)).Decode(&g.Val))
+}
+
+// *JSON implements Sophier interface for any JSON-encodable type. It is
+// serialized as a ByteSlice of the JSON encoded bytes.
+//
+// The value is reset to the zero value before decoding, so fields that are
+// not in the data are not kept from the previous read.
+type JSON[T any] struct {
+	Val T
+}
+
+// Returns a new instance of *JSON as a Sophier
+func NewJSON[T any]() Sophier {
+	return new(JSON[T])
+}
+
+// SophieWriter interface
+func (j JSON[T]) WriteTo(w Writer) error {
+	bs, err := json.Marshal(&j.Val)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	return ByteSlice(bs).WriteTo(w)
+}
+
+// SophieReader interface
+func (j *JSON[T]) ReadFrom(r Reader, l int) error {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	if err := (*ByteSlice)(buf).readFrom(r, l); err != nil {
+		return err
+	}
+	var zero T
+	j.Val = zero
+	return errorsp.WithStacks(json.Unmarshal(*buf, &j.Val))
+}
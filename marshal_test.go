@@ -0,0 +1,68 @@
+package sophie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestBinary(t *testing.T) {
+	ta := Binary[time.Time, *time.Time]{Val: time.Unix(1400000000, 5).UTC()}
+	var tb Binary[time.Time, *time.Time]
+	readWrite(t, &ta, &tb, -1)
+	assert.True(t, "tb.Val.Equal(ta.Val)", tb.Val.Equal(ta.Val))
+
+	// Binary of time.Time is compatible with Time.
+	var buf bytesp.Slice
+	assert.NoError(t, ta.WriteTo(&buf))
+	var tm Time
+	assert.NoError(t, tm.ReadFrom(&buf, len(buf)))
+	assert.True(t, "tm.Equal(ta.Val)", time.Time(tm).Equal(ta.Val))
+
+	_, ok := NewBinary[time.Time]().(*Binary[time.Time, *time.Time])
+	assert.True(t, "ok", ok)
+}
+
+type marshalRecord struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+func TestGob(t *testing.T) {
+	ga := Gob[marshalRecord]{Val: marshalRecord{Name: "abc", Count: 3, Tags: []string{"x"}}}
+	var gb Gob[marshalRecord]
+	readWrite(t, &ga, &gb, -1)
+	assert.Equal(t, "gb", gb, ga)
+
+	// Fields of the previous value are not kept.
+	ga = Gob[marshalRecord]{Val: marshalRecord{Name: "def"}}
+	readWrite(t, &ga, &gb, -1)
+	assert.Equal(t, "gb", gb, ga)
+
+	_, ok := NewGob[int]().(*Gob[int])
+	assert.True(t, "ok", ok)
+}
+
+func TestJSON(t *testing.T) {
+	ja := JSON[marshalRecord]{Val: marshalRecord{Name: "abc", Count: 3, Tags: []string{"x"}}}
+	var jb JSON[marshalRecord]
+	readWrite(t, &ja, &jb, -1)
+	assert.Equal(t, "jb", jb, ja)
+
+	var buf bytesp.Slice
+	assert.NoError(t, JSON[map[string]int]{Val: map[string]int{"a": 1}}.WriteTo(&buf))
+	assert.Equal(t, "buf", string(buf), "\x07{\"a\":1}")
+
+	ja = JSON[marshalRecord]{Val: marshalRecord{Name: "def"}}
+	readWrite(t, &ja, &jb, -1)
+	assert.Equal(t, "jb", jb, ja)
+
+	buf = bytesp.Slice("\x02{x")
+	assert.Error(t, jb.ReadFrom(&buf, len(buf)))
+
+	_, ok := NewJSON[int]().(*JSON[int])
+	assert.True(t, "ok", ok)
+}
@@ -0,0 +1,344 @@
+package sophie
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemFS is a FileSystem keeping files in memory, e.g. for running tests of kv
+// files or mr jobs hermetically. It follows the semantics of LocalFS: Create
+// truncates an existing file and needs the parent directory, Mkdir makes the
+// parents, and Remove removes a directory with everything in it and succeeds
+// if nothing exists. Errors are *os.PathErrors, so os.IsNotExist and similar
+// functions work.
+//
+// Paths are slash or OS separated, and relative paths are relative to the
+// root. It is safe for concurrent use. Written bytes are visible to Stat and
+// readers opened later, while a reader sees the bytes at the time it is
+// opened.
+type MemFS struct {
+	mu   sync.RWMutex
+	root *memNode
+}
+
+type memNode struct {
+	name     string
+	dir      bool
+	perm     os.FileMode
+	modTime  time.Time
+	data     []byte
+	children map[string]*memNode
+}
+
+func newMemDir(name string, perm os.FileMode) *memNode {
+	return &memNode{
+		name:     name,
+		dir:      true,
+		perm:     perm,
+		modTime:  time.Now(),
+		children: make(map[string]*memNode),
+	}
+}
+
+func (n *memNode) info() os.FileInfo {
+	fi := &memFileInfo{
+		name:    n.name,
+		size:    int64(len(n.data)),
+		mode:    n.perm,
+		modTime: n.modTime,
+	}
+	if n.dir {
+		fi.mode |= os.ModeDir
+	}
+	return fi
+}
+
+// NewMemFS returns an empty *MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{root: newMemDir("/", 0755)}
+}
+
+// splitPath returns the components of a path.
+func splitPath(fn string) []string {
+	p := path.Clean("/" + filepath.ToSlash(fn))
+	if p == "/" {
+		return nil
+	}
+	return strings.Split(p[1:], "/")
+}
+
+// lookup returns the node of the components. The lock must be held.
+func (fs *MemFS) lookup(op, fn string, parts []string) (*memNode, error) {
+	n := fs.root
+	for _, part := range parts {
+		if !n.dir {
+			return nil, &os.PathError{Op: op, Path: fn, Err: syscall.ENOTDIR}
+		}
+		child, ok := n.children[part]
+		if !ok {
+			return nil, &os.PathError{Op: op, Path: fn, Err: os.ErrNotExist}
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// lookupParent returns the directory containing the last component, which
+// must exist. The lock must be held.
+func (fs *MemFS) lookupParent(op, fn string, parts []string) (*memNode, string, error) {
+	if len(parts) == 0 {
+		return nil, "", &os.PathError{Op: op, Path: fn, Err: syscall.EISDIR}
+	}
+	parent, err := fs.lookup(op, fn, parts[:len(parts)-1])
+	if err != nil {
+		return nil, "", err
+	}
+	if !parent.dir {
+		return nil, "", &os.PathError{Op: op, Path: fn, Err: syscall.ENOTDIR}
+	}
+	return parent, parts[len(parts)-1], nil
+}
+
+// FileSystem interface
+func (fs *MemFS) Create(fn string) (WriteCloser, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	parent, name, err := fs.lookupParent("open", fn, splitPath(fn))
+	if err != nil {
+		return nil, err
+	}
+	n, ok := parent.children[name]
+	if ok && n.dir {
+		return nil, &os.PathError{Op: "open", Path: fn, Err: syscall.EISDIR}
+	}
+	if !ok {
+		n = &memNode{name: name, perm: 0644}
+		parent.children[name] = n
+		parent.modTime = time.Now()
+	}
+	n.data, n.modTime = nil, time.Now()
+	return &memFileWriter{fs: fs, node: n, name: fn}, nil
+}
+
+// FileSystem interface
+func (fs *MemFS) Open(fn string) (ReadCloser, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	n, err := fs.lookup("open", fn, splitPath(fn))
+	if err != nil {
+		return nil, err
+	}
+	if n.dir {
+		return nil, &os.PathError{Op: "read", Path: fn, Err: syscall.EISDIR}
+	}
+	return &memFileReader{BytesReader: NewBytesReader(n.data[:len(n.data):len(n.data)])}, nil
+}
+
+// FileSystem interface
+func (fs *MemFS) Mkdir(p string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	n := fs.root
+	for _, part := range splitPath(p) {
+		child, ok := n.children[part]
+		if !ok {
+			child = newMemDir(part, perm.Perm())
+			n.children[part] = child
+			n.modTime = time.Now()
+		} else if !child.dir {
+			return &os.PathError{Op: "mkdir", Path: p, Err: syscall.ENOTDIR}
+		}
+		n = child
+	}
+	return nil
+}
+
+// FileSystem interface. The FileInfos are sorted by name.
+func (fs *MemFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	n, err := fs.lookup("open", dir, splitPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	if !n.dir {
+		return nil, &os.PathError{Op: "readdirent", Path: dir, Err: syscall.ENOTDIR}
+	}
+	infos := make([]os.FileInfo, 0, len(n.children))
+	for _, child := range n.children {
+		infos = append(infos, child.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	return infos, nil
+}
+
+// FileSystem interface
+func (fs *MemFS) Stat(fn string) (os.FileInfo, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	n, err := fs.lookup("stat", fn, splitPath(fn))
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+// FileSystem interface
+func (fs *MemFS) Remove(fn string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	parts := splitPath(fn)
+	if len(parts) == 0 {
+		// Removing the root empties it, as RemoveAll does for a directory.
+		fs.root.children = make(map[string]*memNode)
+		return nil
+	}
+	parent, err := fs.lookup("remove", fn, parts[:len(parts)-1])
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !parent.dir {
+		return &os.PathError{Op: "remove", Path: fn, Err: syscall.ENOTDIR}
+	}
+	if _, ok := parent.children[parts[len(parts)-1]]; ok {
+		delete(parent.children, parts[len(parts)-1])
+		parent.modTime = time.Now()
+	}
+	return nil
+}
+
+// FileSystem interface. Like os.Rename, an existing file at newpath is
+// replaced, but not a directory, and errors are *os.LinkErrors.
+func (fs *MemFS) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	oldParts, newParts := splitPath(oldpath), splitPath(newpath)
+	if len(oldParts) == 0 || len(newParts) == 0 {
+		return linkErr(syscall.EBUSY)
+	}
+	oldParent, oldName, err := fs.lookupParent("rename", oldpath, oldParts)
+	if err != nil {
+		return linkErr(err.(*os.PathError).Err)
+	}
+	n, ok := oldParent.children[oldName]
+	if !ok {
+		return linkErr(os.ErrNotExist)
+	}
+	if n.dir && len(newParts) > len(oldParts) && strings.Join(newParts[:len(oldParts)], "/") == strings.Join(oldParts, "/") {
+		// Moving a directory into itself.
+		return linkErr(syscall.EINVAL)
+	}
+	newParent, newName, err := fs.lookupParent("rename", newpath, newParts)
+	if err != nil {
+		return linkErr(err.(*os.PathError).Err)
+	}
+	if old, ok := newParent.children[newName]; ok {
+		if old == n {
+			return nil
+		}
+		if old.dir {
+			return linkErr(syscall.EEXIST)
+		}
+		if n.dir {
+			return linkErr(syscall.ENOTDIR)
+		}
+	}
+	delete(oldParent.children, oldName)
+	n.name = newName
+	newParent.children[newName] = n
+	now := time.Now()
+	oldParent.modTime, newParent.modTime = now, now
+	return nil
+}
+
+// memFileWriter writes to a file of a MemFS. The bytes are appended to the
+// file directly.
+type memFileWriter struct {
+	fs     *MemFS
+	node   *memNode
+	name   string
+	closed bool
+}
+
+// io.Writer interface
+func (w *memFileWriter) Write(p []byte) (int, error) {
+	w.fs.mu.Lock()
+	defer w.fs.mu.Unlock()
+
+	if w.closed {
+		return 0, &os.PathError{Op: "write", Path: w.name, Err: os.ErrClosed}
+	}
+	w.node.data = append(w.node.data, p...)
+	w.node.modTime = time.Now()
+	return len(p), nil
+}
+
+// io.ByteWriter interface
+func (w *memFileWriter) WriteByte(b byte) error {
+	_, err := w.Write([]byte{b})
+	return err
+}
+
+// io.Closer interface
+func (w *memFileWriter) Close() error {
+	w.fs.mu.Lock()
+	defer w.fs.mu.Unlock()
+
+	if w.closed {
+		return &os.PathError{Op: "close", Path: w.name, Err: os.ErrClosed}
+	}
+	w.closed = true
+	return nil
+}
+
+// memFileReader reads a snapshot of a file of a MemFS.
+type memFileReader struct {
+	*BytesReader
+	EmptyClose
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// os.FileInfo interface
+func (fi *memFileInfo) Name() string { return fi.name }
+
+// os.FileInfo interface
+func (fi *memFileInfo) Size() int64 { return fi.size }
+
+// os.FileInfo interface
+func (fi *memFileInfo) Mode() os.FileMode { return fi.mode }
+
+// os.FileInfo interface
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+
+// os.FileInfo interface
+func (fi *memFileInfo) IsDir() bool { return fi.mode.IsDir() }
+
+// os.FileInfo interface
+func (fi *memFileInfo) Sys() interface{} { return nil }
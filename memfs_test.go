@@ -0,0 +1,136 @@
+package sophie
+
+import (
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestMemFS(t *testing.T) {
+	fs := NewMemFS()
+
+	// Parent directory not created.
+	_, err := fs.Create("/a/b")
+	assert.True(t, "IsNotExist", os.IsNotExist(err))
+
+	assert.NoError(t, fs.Mkdir("/a/c", 0755))
+	w, err := fs.Create("a/b")
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, String("abc").WriteTo(w))
+
+	fi, err := fs.Stat("/a/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "Name", fi.Name(), "b")
+	assert.Equal(t, "Size", fi.Size(), int64(4))
+	assert.False(t, "IsDir", fi.IsDir())
+
+	// A reader sees the bytes at the time it is opened.
+	r, err := fs.Open("/a/b")
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, w.WriteByte('d'))
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.WriteByte('e'))
+
+	var s String
+	assert.NoError(t, s.ReadFrom(r, UNKNOWN_LEN))
+	assert.Equal(t, "s", s, String("abc"))
+	_, err = r.ReadByte()
+	assert.Equal(t, "err", err, io.EOF)
+	assert.NoError(t, r.Close())
+
+	infos, err := fs.ReadDir("/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "len(infos)", len(infos), 2)
+	assert.Equal(t, "infos[0].Name", infos[0].Name(), "b")
+	assert.Equal(t, "infos[0].Size", infos[0].Size(), int64(5))
+	assert.Equal(t, "infos[1].Name", infos[1].Name(), "c")
+	assert.True(t, "infos[1].IsDir", infos[1].IsDir())
+	assert.Equal(t, "infos[1].Mode", infos[1].Mode(), os.ModeDir|0755)
+
+	// Create truncates an existing file.
+	w, err = fs.Create("/a/b")
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, w.Close())
+	fi, err = fs.Stat("/a/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "Size", fi.Size(), int64(0))
+
+	// Errors
+	_, err = fs.Open("/a/d")
+	assert.True(t, "IsNotExist", os.IsNotExist(err))
+	_, err = fs.Open("/a")
+	assert.Error(t, err)
+	_, err = fs.Create("/a/c")
+	assert.Error(t, err)
+	_, err = fs.ReadDir("/a/b")
+	assert.Error(t, err)
+	assert.Error(t, fs.Mkdir("/a/b/c", 0755))
+	_, err = fs.Stat("/a/b/c")
+	assert.Error(t, err)
+
+	// Remove
+	assert.NoError(t, fs.Remove("/a/d"))
+	assert.NoError(t, fs.Remove("/x/y"))
+	assert.NoError(t, fs.Remove("/a"))
+	_, err = fs.Stat("/a/c")
+	assert.True(t, "IsNotExist", os.IsNotExist(err))
+	infos, err = fs.ReadDir("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "len(infos)", len(infos), 0)
+}
+
+func TestMemFS_Concurrent(t *testing.T) {
+	fs := NewMemFS()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dir := LocalFsPath("/d").Join(string(rune('a' + i)))
+			dir.Fs = fs
+			assert.NoError(t, dir.Mkdir(0755))
+			w, err := dir.Join("f").Create()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NoError(t, VInt(i).WriteTo(w))
+			assert.NoError(t, w.Close())
+		}(i)
+	}
+	wg.Wait()
+	infos, err := fs.ReadDir("/d")
+	assert.NoError(t, err)
+	assert.Equal(t, "len(infos)", len(infos), 10)
+}
+
+func TestMemFS_Rename(t *testing.T) {
+	fs := NewMemFS()
+	assert.NoError(t, fs.Mkdir("/a/b", 0755))
+	for _, fn := range []string{"/a/f", "/a/g"} {
+		w, err := fs.Create(fn)
+		assert.NoErrorOrDie(t, err)
+		assert.NoError(t, w.WriteByte(1))
+		assert.NoError(t, w.Close())
+	}
+
+	// Replaces an existing file.
+	assert.NoError(t, fs.Rename("/a/f", "/a/g"))
+	_, err := fs.Stat("/a/f")
+	assert.True(t, "IsNotExist", os.IsNotExist(err))
+
+	assert.NoError(t, fs.Rename("/a", "/c"))
+	fi, err := fs.Stat("/c/g")
+	assert.NoError(t, err)
+	assert.Equal(t, "Name", fi.Name(), "g")
+	assert.Equal(t, "Size", fi.Size(), int64(1))
+
+	// Errors
+	assert.Error(t, fs.Rename("/a", "/d"))
+	assert.Error(t, fs.Rename("/c/g", "/c/b"))
+	assert.Error(t, fs.Rename("/c/b", "/c/g"))
+	assert.Error(t, fs.Rename("/c", "/c/b/d"))
+	assert.Error(t, fs.Rename("/c/g", "/x/g"))
+}
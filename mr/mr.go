@@ -123,7 +123,8 @@ type MrJob struct {
 	NewReducerF func(part int) Reducer
 
 	// The Sorter that sorts kv pairs mapped by Mappers and provides
-	// SophierIterator for Reducers.
+	// SophierIterator for Reducers. Keys are compared by their serialized
+	// bytes, use the types in package keys for meaningful orders.
 	Sorter Sorter
 
 	// The source Inputs
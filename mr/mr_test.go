@@ -229,8 +229,14 @@ func TestMapReduce(t *testing.T) {
 
 func TestMRFromFile(t *testing.T) {
 	fmt.Println(">>> TestMRFromFile")
-	fpRoot := sophie.LocalFsPath(".")
+	testMRFromFile(t, sophie.LocalFsPath("."))
+}
+
+func TestMRFromFile_MemFS(t *testing.T) {
+	testMRFromFile(t, sophie.FsPath{Fs: sophie.NewMemFS(), Path: "/"})
+}
 
+func testMRFromFile(t *testing.T, fpRoot sophie.FsPath) {
 	mrin := fpRoot.Join("mrin")
 	mrin.Mkdir(0755)
 
@@ -3,6 +3,7 @@ package mr
 import (
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"sync"
 
@@ -116,7 +117,7 @@ func (ms *memSorter) Iterate(c []sophie.Collector, r Reducer) error {
 			return val, nil
 		}
 		if err := r.Reduce(key, valIter, c); err != nil {
-			return errorsp.WithStacksAndMessage(err, "reduce %v failed", key)
+			return errorsp.WithStacksAndMessage(err, "reduce %s failed", sophie.Text(key))
 		}
 		// iterate to end in case the reducer doesn't
 		for curVal >= 0 {
@@ -162,6 +163,7 @@ func (ms *MemSorters) CollectTo(part int, key, val sophie.SophieWriter) error {
 	sorter.Lock()
 	defer sorter.Unlock()
 
+	sorter.Buffer = slices.Grow(sorter.Buffer, sizeHint(key)+sizeHint(val))
 	sorter.KeyOffs.Add(len(sorter.Buffer))
 	key.WriteTo(&sorter.Buffer)
 	sorter.ValOffs.Add(len(sorter.Buffer))
@@ -218,8 +220,18 @@ func (mo *mapOut) Collect(key, val sophie.SophieWriter) error {
 	return mo.writer.Collect(key, val)
 }
 
+// sizeHint returns the encoded length of s if it is a sophie.Sizer, or 0
+// otherwise. It is used to pre-size buffers.
+func sizeHint(s sophie.SophieWriter) int {
+	if sz, ok := s.(sophie.Sizer); ok {
+		return sz.EncodedLen()
+	}
+	return 0
+}
+
 func sophieCmp(a, b sophie.Sophier) int {
-	var bufA, bufB bytesp.Slice
+	bufA := make(bytesp.Slice, 0, sizeHint(a))
+	bufB := make(bytesp.Slice, 0, sizeHint(b))
 	a.WriteTo(&bufA)
 	b.WriteTo(&bufB)
 	return bytesCmp(bufA, bufB)
@@ -260,7 +272,7 @@ func (mo *mapOut) Iterate(c []sophie.Collector, r Reducer) error {
 			return s, nil
 		}
 		if err := r.Reduce(key, valIter, c); err != nil {
-			return errorsp.WithStacksAndMessage(err, "reduce %v failed", key)
+			return errorsp.WithStacksAndMessage(err, "reduce %s failed", sophie.Text(key))
 		}
 		// r.Reduce could return before iterating all values
 		for curVal != nil {
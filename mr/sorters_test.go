@@ -90,3 +90,9 @@ func TestFileSorter(t *testing.T) {
 	s := NewFileSorter(fpRoot.Join("tmp"))
 	checkSorter(t, s)
 }
+
+func TestFileSorter_Compressed(t *testing.T) {
+	fpRoot := sophie.FsPath{Fs: sophie.CompressedFS{FileSystem: sophie.NewMemFS(), Default: sophie.Gzip}, Path: "/"}
+	s := NewFileSorter(fpRoot.Join("tmp"))
+	checkSorter(t, s)
+}
@@ -0,0 +1,442 @@
+package sophie
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+)
+
+// WireType is the type of the payload of a field in a record, which tells a
+// RecordReader how to skip the field if it is unknown.
+type WireType int
+
+const (
+	// A vint, for integers and bools.
+	WireVarint WireType = iota
+	// 4 bytes in little-endian, for float32s.
+	WireFixed32
+	// 8 bytes in little-endian, for float64s.
+	WireFixed64
+	// A VInt of the length followed by the bytes, for strings, byte slices and
+	// any other Sophiers.
+	WireBytes
+)
+
+// RecordWriter writes a record, i.e. serialized fields, each of which has a
+// positive field ID. A field is serialized as a vint of (id << 2 | WireType)
+// followed by the payload. A record is terminated by a zero byte.
+//
+// Since a RecordReader skips the fields it does not know, fields can be added
+// to or removed from a record without breaking the existing data, as long as
+// the IDs are never reused and the WireType of a field never changes.
+//
+// The first error is kept and returned by End, so the results of the field
+// methods need not be checked, e.g.:
+//
+//	func (e Event) WriteTo(w sophie.Writer) error {
+//		rw := sophie.NewRecordWriter(w)
+//		rw.Int(1, e.ID)
+//		rw.String(2, e.Name)
+//		rw.Sophier(3, e.Attr)
+//		return rw.End()
+//	}
+//
+// Record types can also be generated by sophiegen.
+type RecordWriter struct {
+	w   Writer
+	buf bytesp.Slice
+	err error
+}
+
+// NewRecordWriter returns a *RecordWriter writing a record to w.
+func NewRecordWriter(w Writer) *RecordWriter {
+	return &RecordWriter{w: w}
+}
+
+func (rw *RecordWriter) key(id int, wt WireType) bool {
+	if rw.err != nil {
+		return false
+	}
+	if id <= 0 {
+		rw.err = errorsp.NewWithStacks("invalid field ID %d", id)
+		return false
+	}
+	rw.err = writeUVarint(rw.w, uint64(id)<<2|uint64(wt))
+	return rw.err == nil
+}
+
+// Uint writes a field of an unsigned integer with WireVarint.
+func (rw *RecordWriter) Uint(id int, v uint64) {
+	if rw.key(id, WireVarint) {
+		rw.err = writeUVarint(rw.w, v)
+	}
+}
+
+// Int writes a field of a signed integer, zigzag encoded, with WireVarint.
+func (rw *RecordWriter) Int(id int, v int64) {
+	rw.Uint(id, zigzag(v))
+}
+
+// Bool writes a field of a bool with WireVarint.
+func (rw *RecordWriter) Bool(id int, b bool) {
+	var v uint64
+	if b {
+		v = 1
+	}
+	rw.Uint(id, v)
+}
+
+// Float32 writes a field of a float32 with WireFixed32.
+func (rw *RecordWriter) Float32(id int, f float32) {
+	if rw.key(id, WireFixed32) {
+		var arr [4]byte
+		binary.LittleEndian.PutUint32(arr[:], math.Float32bits(f))
+		_, err := rw.w.Write(arr[:])
+		rw.err = errorsp.WithStacks(err)
+	}
+}
+
+// Float64 writes a field of a float64 with WireFixed64.
+func (rw *RecordWriter) Float64(id int, f float64) {
+	if rw.key(id, WireFixed64) {
+		var arr [8]byte
+		binary.LittleEndian.PutUint64(arr[:], math.Float64bits(f))
+		_, err := rw.w.Write(arr[:])
+		rw.err = errorsp.WithStacks(err)
+	}
+}
+
+// Bytes writes a field of a byte slice with WireBytes.
+func (rw *RecordWriter) Bytes(id int, bs []byte) {
+	if rw.key(id, WireBytes) {
+		rw.err = ByteSlice(bs).WriteTo(rw.w)
+	}
+}
+
+// String writes a field of a string with WireBytes.
+func (rw *RecordWriter) String(id int, s string) {
+	if rw.key(id, WireBytes) {
+		rw.err = String(s).WriteTo(rw.w)
+	}
+}
+
+// Sophier writes a field of a Sophier with WireBytes.
+func (rw *RecordWriter) Sophier(id int, s SophieWriter) {
+	rw.Field(id, s.WriteTo)
+}
+
+// Field writes a field with WireBytes whose payload is written by write.
+func (rw *RecordWriter) Field(id int, write func(w Writer) error) {
+	if rw.err != nil {
+		return
+	}
+	start := len(rw.buf)
+	if rw.err = write(&rw.buf); rw.err != nil {
+		return
+	}
+	payload := rw.buf[start:]
+	if rw.key(id, WireBytes) {
+		rw.err = ByteSlice(payload).WriteTo(rw.w)
+	}
+	rw.buf = rw.buf[:start]
+}
+
+// End terminates the record and returns the first error, if any.
+func (rw *RecordWriter) End() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	rw.err = errorsp.WithStacks(rw.w.WriteByte(0))
+	return rw.err
+}
+
+// RecordReader reads fields of a record written by RecordWriter. Call Next to
+// move to the next field, and one of the value methods matching the WireType
+// to read it. Fields not read are skipped. The first error is kept and
+// returned by Err, and the value methods return zero values after an error.
+//
+// A field which is not in the data keeps its value, so ReadFrom usually
+// resets the struct first, e.g.:
+//
+//	func (e *Event) ReadFrom(r sophie.Reader, l int) error {
+//		*e = Event{}
+//		rr := sophie.NewRecordReader(r)
+//		for rr.Next() {
+//			switch rr.Field() {
+//			case 1:
+//				e.ID = rr.Int()
+//			case 2:
+//				e.Name = rr.String()
+//			case 3:
+//				rr.Sophier(&e.Attr)
+//			}
+//		}
+//		return rr.Err()
+//	}
+type RecordReader struct {
+	r       Reader
+	id      int
+	wt      WireType
+	pending bool
+	done    bool
+	err     error
+}
+
+// NewRecordReader returns a *RecordReader reading a record from r.
+func NewRecordReader(r Reader) *RecordReader {
+	return &RecordReader{r: r}
+}
+
+// Next moves to the next field. It returns false at the end of the record or
+// if any error occurred.
+func (rr *RecordReader) Next() bool {
+	if rr.pending {
+		rr.Skip()
+	}
+	if rr.err != nil || rr.done {
+		return false
+	}
+	key, err := readUVarint(rr.r)
+	if err != nil {
+		rr.setErr(err)
+		return false
+	}
+	if key == 0 {
+		rr.done = true
+		return false
+	}
+	rr.id, rr.wt, rr.pending = int(key>>2), WireType(key&3), true
+	if rr.id == 0 {
+		rr.setErr(errorsp.WithStacksAndMessage(ErrBadFormat, "zero field ID"))
+		return false
+	}
+	return true
+}
+
+// Field returns the ID of the current field.
+func (rr *RecordReader) Field() int {
+	return rr.id
+}
+
+// WireType returns the WireType of the current field.
+func (rr *RecordReader) WireType() WireType {
+	return rr.wt
+}
+
+// Err returns the first error, if any.
+func (rr *RecordReader) Err() error {
+	return rr.err
+}
+
+func (rr *RecordReader) setErr(err error) {
+	if errorsp.Cause(err) == io.EOF {
+		err = errorsp.WithStacks(io.ErrUnexpectedEOF)
+	}
+	rr.err, rr.pending = err, false
+}
+
+// start checks the current field can be read as wt.
+func (rr *RecordReader) start(wt WireType) bool {
+	if rr.err != nil {
+		return false
+	}
+	if !rr.pending {
+		rr.setErr(errorsp.NewWithStacks("no field to read"))
+		return false
+	}
+	if rr.wt != wt {
+		rr.setErr(errorsp.WithStacksAndMessage(ErrBadFormat, "field %d has wire type %d, but %d is expected", rr.id, rr.wt, wt))
+		return false
+	}
+	rr.pending = false
+	return true
+}
+
+// readLen reads the length of a WireBytes payload.
+func (rr *RecordReader) readLen() (int, bool) {
+	if !rr.start(WireBytes) {
+		return 0, false
+	}
+	n, err := readUVarint(rr.r)
+	if err == nil {
+		err = DecodeLimits.CheckLen(int(n))
+	}
+	if err != nil {
+		rr.setErr(err)
+		return 0, false
+	}
+	return int(n), true
+}
+
+// Skip skips the payload of the current field.
+func (rr *RecordReader) Skip() {
+	if !rr.pending || rr.err != nil {
+		return
+	}
+	var n int
+	switch rr.wt {
+	case WireVarint:
+		rr.Uint()
+		return
+	case WireFixed32:
+		n = 4
+	case WireFixed64:
+		n = 8
+	default:
+		var ok bool
+		if n, ok = rr.readLen(); !ok {
+			return
+		}
+	}
+	rr.pending = false
+	rr.skip(n)
+}
+
+func (rr *RecordReader) skip(n int) {
+	if skipped, err := rr.r.Skip(int64(n)); err != nil || skipped < int64(n) {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		rr.setErr(errorsp.WithStacks(err))
+	}
+}
+
+// Uint reads the current field as an unsigned integer.
+func (rr *RecordReader) Uint() uint64 {
+	if !rr.start(WireVarint) {
+		return 0
+	}
+	v, err := readUVarint(rr.r)
+	if err != nil {
+		rr.setErr(err)
+		return 0
+	}
+	return v
+}
+
+// Int reads the current field as a signed integer.
+func (rr *RecordReader) Int() int64 {
+	return unzigzag(rr.Uint())
+}
+
+// Bool reads the current field as a bool.
+func (rr *RecordReader) Bool() bool {
+	return rr.Uint() != 0
+}
+
+// Float32 reads the current field as a float32.
+func (rr *RecordReader) Float32() float32 {
+	if !rr.start(WireFixed32) {
+		return 0
+	}
+	var arr [4]byte
+	if _, err := io.ReadFull(rr.r, arr[:]); err != nil {
+		rr.setErr(errorsp.WithStacks(err))
+		return 0
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(arr[:]))
+}
+
+// Float64 reads the current field as a float64.
+func (rr *RecordReader) Float64() float64 {
+	if !rr.start(WireFixed64) {
+		return 0
+	}
+	var arr [8]byte
+	if _, err := io.ReadFull(rr.r, arr[:]); err != nil {
+		rr.setErr(errorsp.WithStacks(err))
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(arr[:]))
+}
+
+// Bytes reads the current field as a byte slice, reusing the capacity of buf.
+func (rr *RecordReader) Bytes(buf []byte) []byte {
+	n, ok := rr.readLen()
+	if !ok {
+		return buf[:0]
+	}
+	buf = growBytes(buf, n)
+	if _, err := io.ReadFull(rr.r, buf); err != nil {
+		rr.setErr(errorsp.WithStacks(err))
+		return buf[:0]
+	}
+	return buf
+}
+
+// String reads the current field as a string.
+func (rr *RecordReader) String() string {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	*buf = rr.Bytes(*buf)
+	return string(*buf)
+}
+
+// Sophier reads the current field into s.
+func (rr *RecordReader) Sophier(s SophieReader) {
+	rr.Read(s.ReadFrom)
+}
+
+// Read reads the current field of WireBytes with read, which is called with a
+// Reader limited to the payload and the length of it. Bytes not read by read
+// are skipped.
+func (rr *RecordReader) Read(read func(r Reader, l int) error) {
+	n, ok := rr.readLen()
+	if !ok {
+		return
+	}
+	lr := &limitedReader{r: rr.r, n: int64(n)}
+	if err := read(lr, n); err != nil {
+		rr.setErr(err)
+		return
+	}
+	rr.skip(int(lr.n))
+}
+
+// limitedReader is a Reader reading at most n bytes from r.
+type limitedReader struct {
+	r Reader
+	n int64
+}
+
+// io.Reader interface
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > lr.n {
+		p = p[:lr.n]
+	}
+	n, err := lr.r.Read(p)
+	lr.n -= int64(n)
+	return n, err
+}
+
+// io.ByteReader interface
+func (lr *limitedReader) ReadByte() (byte, error) {
+	if lr.n <= 0 {
+		return 0, io.EOF
+	}
+	b, err := lr.r.ReadByte()
+	if err == nil {
+		lr.n--
+	}
+	return b, err
+}
+
+// sophie.Reader interface
+func (lr *limitedReader) Skip(n int64) (int64, error) {
+	var err error
+	if n > lr.n {
+		n, err = lr.n, io.EOF
+	}
+	skipped, err2 := lr.r.Skip(n)
+	lr.n -= skipped
+	if err2 != nil {
+		return skipped, err2
+	}
+	return skipped, err
+}
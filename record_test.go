@@ -0,0 +1,124 @@
+package sophie
+
+import (
+	"io"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestRecord(t *testing.T) {
+	var buf bytesp.Slice
+	rw := NewRecordWriter(&buf)
+	rw.Uint(1, 300)
+	rw.Int(2, -5)
+	rw.Bool(3, true)
+	rw.Float32(4, 1.5)
+	rw.Float64(5, -2.5)
+	rw.Bytes(6, []byte("bytes"))
+	rw.String(7, "str")
+	rw.Sophier(8, Pair[String, VInt, *String, *VInt]{First: "a", Second: 1})
+	rw.Field(100, func(w Writer) error {
+		return RawString("raw").WriteTo(w)
+	})
+	assert.NoError(t, rw.End())
+	encoded := append([]byte(nil), buf...)
+
+	rr := NewRecordReader(&buf)
+	var fields []int
+	for rr.Next() {
+		fields = append(fields, rr.Field())
+		switch rr.Field() {
+		case 1:
+			assert.Equal(t, "WireType", rr.WireType(), WireVarint)
+			assert.Equal(t, "Uint", rr.Uint(), uint64(300))
+		case 2:
+			assert.Equal(t, "Int", rr.Int(), int64(-5))
+		case 3:
+			assert.True(t, "Bool", rr.Bool())
+		case 4:
+			assert.Equal(t, "Float32", rr.Float32(), float32(1.5))
+		case 5:
+			assert.Equal(t, "Float64", rr.Float64(), -2.5)
+		case 6:
+			assert.Equal(t, "Bytes", string(rr.Bytes(nil)), "bytes")
+		case 7:
+			assert.Equal(t, "String", rr.String(), "str")
+		case 8:
+			var p Pair[String, VInt, *String, *VInt]
+			rr.Sophier(&p)
+			assert.Equal(t, "p", p, Pair[String, VInt, *String, *VInt]{First: "a", Second: 1})
+		case 100:
+			rr.Read(func(r Reader, l int) error {
+				var s RawString
+				assert.NoError(t, s.ReadFrom(r, l))
+				assert.Equal(t, "s", s, RawString("raw"))
+				return nil
+			})
+		}
+	}
+	assert.NoError(t, rr.Err())
+	assert.Equal(t, "fields", fields, []int{1, 2, 3, 4, 5, 6, 7, 8, 100})
+	assert.Equal(t, "len(buf)", len(buf), 0)
+
+	// Unknown fields are skipped.
+	buf = append(buf[:0], encoded...)
+	buf = append(buf, "tail"...)
+	rr = NewRecordReader(&buf)
+	for rr.Next() {
+		if rr.Field() == 7 {
+			assert.Equal(t, "String", rr.String(), "str")
+		}
+	}
+	assert.NoError(t, rr.Err())
+	assert.Equal(t, "buf", string(buf), "tail")
+
+	// Bytes not read in Read are skipped.
+	buf = append(buf[:0], encoded...)
+	rr = NewRecordReader(&buf)
+	for rr.Next() {
+		if rr.Field() == 8 {
+			rr.Read(func(r Reader, l int) error {
+				var s String
+				return s.ReadFrom(r, UNKNOWN_LEN)
+			})
+		}
+	}
+	assert.NoError(t, rr.Err())
+	assert.Equal(t, "len(buf)", len(buf), 0)
+}
+
+func TestRecord_Errors(t *testing.T) {
+	var buf bytesp.Slice
+	rw := NewRecordWriter(&buf)
+	rw.Uint(0, 1)
+	rw.String(1, "a")
+	assert.Error(t, rw.End())
+
+	// Mismatched wire type
+	buf = buf[:0]
+	rw = NewRecordWriter(&buf)
+	rw.String(1, "a")
+	assert.NoError(t, rw.End())
+	rr := NewRecordReader(&buf)
+	assert.True(t, "rr.Next()", rr.Next())
+	assert.Equal(t, "rr.Uint()", rr.Uint(), uint64(0))
+	assert.False(t, "rr.Next()", rr.Next())
+	assert.Equal(t, "rr.Err()", errorsp.Cause(rr.Err()), ErrBadFormat)
+
+	// Truncated
+	buf = bytesp.Slice("\x07\x05ab")
+	rr = NewRecordReader(&buf)
+	for rr.Next() {
+	}
+	assert.Equal(t, "rr.Err()", errorsp.Cause(rr.Err()), io.ErrUnexpectedEOF)
+
+	// Missing the terminal
+	buf = bytesp.Slice("\x04\x01")
+	rr = NewRecordReader(&buf)
+	for rr.Next() {
+	}
+	assert.Equal(t, "rr.Err()", errorsp.Cause(rr.Err()), io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,616 @@
+package sophie
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golangplus/errors"
+)
+
+// StructSophier wraps a pointer to a struct and implements Sophier interface
+// using reflections. It is convenient for prototyping, but slower than
+// hand-written or generated (by sophiegen) Sophiers.
+//
+// Exported fields are serialized in their declaration order with encodings
+// chosen by their types, or specified by the sophie tags:
+//
+//	vint vint64 uvint svint int8 int16 int32 int64 uint8 uint16 uint32 uint64
+//	float32 float64 bool string bytes time
+//
+// which are the same as those of sophiegen, so the serialized bytes are the
+// same as those of the generated code. The tag applies to the elements for
+// slices and pointers, and to the values for maps. Fields tagged with
+// `sophie:"-"` and unexported fields are ignored.
+type StructSophier struct {
+	ptr  reflect.Value
+	plan *structPlan
+}
+
+// NewStructSophier returns a *StructSophier wrapping ptr, which must be a
+// non-nil pointer to a struct. The serialization plan of each type is
+// computed once and cached.
+func NewStructSophier(ptr interface{}) (*StructSophier, error) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, errorsp.NewWithStacks("expecting a non-nil pointer to a struct, but got %T", ptr)
+	}
+	plan, err := planOf(v.Elem().Type())
+	if err != nil {
+		return nil, err
+	}
+	return &StructSophier{ptr: v, plan: plan}, nil
+}
+
+// NewStructSophierF returns a factory func creating *StructSophiers wrapping
+// new structs of the same type as the one ptr points to. It can be used as
+// NewKeyF/NewValF in mr jobs.
+func NewStructSophierF(ptr interface{}) (func() Sophier, error) {
+	s, err := NewStructSophier(ptr)
+	if err != nil {
+		return nil, err
+	}
+	tp := s.ptr.Elem().Type()
+	return func() Sophier {
+		return &StructSophier{ptr: reflect.New(tp), plan: s.plan}
+	}, nil
+}
+
+// Val returns the wrapped pointer.
+func (s *StructSophier) Val() interface{} {
+	return s.ptr.Interface()
+}
+
+// SophieWriter interface
+func (s *StructSophier) WriteTo(w Writer) error {
+	return s.plan.write(w, s.ptr.Elem())
+}
+
+// SophieReader interface
+func (s *StructSophier) ReadFrom(r Reader, l int) error {
+	return s.plan.read(r, s.ptr.Elem())
+}
+
+// codec writes/reads a reflect.Value of a specific type. When reading, v is
+// always addressable.
+type codec interface {
+	write(w Writer, v reflect.Value) error
+	read(r Reader, v reflect.Value) error
+}
+
+type fieldPlan struct {
+	index int
+	codec codec
+}
+
+type structPlan struct {
+	fields []fieldPlan
+}
+
+func (p *structPlan) write(w Writer, v reflect.Value) error {
+	for _, f := range p.fields {
+		if err := f.codec.write(w, v.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *structPlan) read(r Reader, v reflect.Value) error {
+	for _, f := range p.fields {
+		if err := f.codec.read(r, v.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	plansLock sync.RWMutex
+	plans     = make(map[reflect.Type]*structPlan)
+)
+
+func planOf(tp reflect.Type) (*structPlan, error) {
+	plansLock.RLock()
+	plan, ok := plans[tp]
+	plansLock.RUnlock()
+	if ok {
+		return plan, nil
+	}
+
+	plansLock.Lock()
+	defer plansLock.Unlock()
+	building := make(map[reflect.Type]*structPlan)
+	plan, err := buildPlan(tp, building)
+	if err != nil {
+		return nil, err
+	}
+	for t, p := range building {
+		plans[t] = p
+	}
+	return plan, nil
+}
+
+// buildPlan computes the plan of a struct type. Must be called with plansLock
+// locked. building contains the plans being built, which is necessary for
+// recursive types.
+func buildPlan(tp reflect.Type, building map[reflect.Type]*structPlan) (*structPlan, error) {
+	if plan, ok := plans[tp]; ok {
+		return plan, nil
+	}
+	if plan, ok := building[tp]; ok {
+		return plan, nil
+	}
+	plan := &structPlan{}
+	building[tp] = plan
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		enc := f.Tag.Get("sophie")
+		if enc == "-" || f.PkgPath != "" {
+			continue
+		}
+		c, err := codecOf(f.Type, enc, building)
+		if err != nil {
+			return nil, errorsp.WithStacksAndMessage(err, "field %s.%s", tp.Name(), f.Name)
+		}
+		plan.fields = append(plan.fields, fieldPlan{index: i, codec: c})
+	}
+	return plan, nil
+}
+
+var (
+	sophierType = reflect.TypeOf((*Sophier)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+)
+
+// codecOf returns the codec of a type with a tag encoding.
+func codecOf(tp reflect.Type, enc string, building map[reflect.Type]*structPlan) (codec, error) {
+	if enc != "" {
+		switch tp.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Ptr:
+			if tp.Kind() == reflect.Slice && tp.Elem().Kind() == reflect.Uint8 && enc == "bytes" {
+				return bytesCodec{}, nil
+			}
+			// enc applies to the elements
+		default:
+			return basicCodec(tp, enc)
+		}
+	}
+	if reflect.PtrTo(tp).Implements(sophierType) {
+		return sophierCodec{}, nil
+	}
+	switch {
+	case tp == timeType:
+		return basicCodec(tp, "time")
+	case tp.Kind() == reflect.Slice && tp.Elem().Kind() == reflect.Uint8 && enc == "":
+		return bytesCodec{}, nil
+	}
+	switch tp.Kind() {
+	case reflect.Int:
+		return basicCodec(tp, "vint")
+	case reflect.Int64:
+		return basicCodec(tp, "vint64")
+	case reflect.Uint, reflect.Uint64:
+		return basicCodec(tp, "uvint")
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+		return basicCodec(tp, tp.Kind().String())
+
+	case reflect.Slice:
+		el, err := codecOf(tp.Elem(), enc, building)
+		if err != nil {
+			return nil, err
+		}
+		return sliceCodec{el}, nil
+
+	case reflect.Map:
+		if !isOrderedKind(tp.Key().Kind()) {
+			return nil, errorsp.NewWithStacks("unsupported map key type %v", tp.Key())
+		}
+		key, err := codecOf(tp.Key(), "", building)
+		if err != nil {
+			return nil, err
+		}
+		val, err := codecOf(tp.Elem(), enc, building)
+		if err != nil {
+			return nil, err
+		}
+		return mapCodec{key: key, val: val}, nil
+
+	case reflect.Ptr:
+		el, err := codecOf(tp.Elem(), enc, building)
+		if err != nil {
+			return nil, err
+		}
+		return ptrCodec{el}, nil
+
+	case reflect.Struct:
+		plan, err := buildPlan(tp, building)
+		if err != nil {
+			return nil, err
+		}
+		return plan, nil
+	}
+	return nil, errorsp.NewWithStacks("unsupported type %v", tp)
+}
+
+func isOrderedKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return false
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+// intEncoding writes/reads an integer as the bits of an uint64.
+type intEncoding struct {
+	write func(w Writer, x uint64) error
+	read  func(r Reader) (uint64, error)
+}
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+func intEncodingOf[S integer, PS interface {
+	*S
+	Sophier
+}]() intEncoding {
+	return intEncoding{
+		write: func(w Writer, x uint64) error {
+			s := S(x)
+			return PS(&s).WriteTo(w)
+		},
+		read: func(r Reader) (uint64, error) {
+			var s S
+			err := PS(&s).ReadFrom(r, UNKNOWN_LEN)
+			return uint64(s), err
+		},
+	}
+}
+
+var intEncodings = map[string]intEncoding{
+	"vint":   intEncodingOf[VInt](),
+	"vint64": intEncodingOf[VInt64](),
+	"uvint":  intEncodingOf[UVInt64](),
+	"svint":  intEncodingOf[SVInt64](),
+	"int8":   intEncodingOf[Int8](),
+	"int16":  intEncodingOf[Int16](),
+	"int32":  intEncodingOf[Int32](),
+	"int64":  intEncodingOf[Int64](),
+	"uint8":  intEncodingOf[Uint8](),
+	"uint16": intEncodingOf[Uint16](),
+	"uint32": intEncodingOf[Uint32](),
+	"uint64": intEncodingOf[Uint64](),
+}
+
+// intCodec is the codec of integers of any kind with an intEncoding.
+type intCodec struct {
+	enc intEncoding
+}
+
+func (c intCodec) write(w Writer, v reflect.Value) error {
+	if isIntKind(v.Kind()) {
+		return c.enc.write(w, uint64(v.Int()))
+	}
+	return c.enc.write(w, v.Uint())
+}
+
+func (c intCodec) read(r Reader, v reflect.Value) error {
+	x, err := c.enc.read(r)
+	if err != nil {
+		return err
+	}
+	if isIntKind(v.Kind()) {
+		v.SetInt(int64(x))
+	} else {
+		v.SetUint(x)
+	}
+	return nil
+}
+
+type float32Codec struct{}
+
+func (float32Codec) write(w Writer, v reflect.Value) error {
+	return Float32(v.Float()).WriteTo(w)
+}
+
+func (float32Codec) read(r Reader, v reflect.Value) error {
+	var f Float32
+	if err := f.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.SetFloat(float64(f))
+	return nil
+}
+
+type float64Codec struct{}
+
+func (float64Codec) write(w Writer, v reflect.Value) error {
+	return Float64(v.Float()).WriteTo(w)
+}
+
+func (float64Codec) read(r Reader, v reflect.Value) error {
+	var f Float64
+	if err := f.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.SetFloat(float64(f))
+	return nil
+}
+
+type boolCodec struct{}
+
+func (boolCodec) write(w Writer, v reflect.Value) error {
+	return Bool(v.Bool()).WriteTo(w)
+}
+
+func (boolCodec) read(r Reader, v reflect.Value) error {
+	var b Bool
+	if err := b.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.SetBool(bool(b))
+	return nil
+}
+
+type stringCodec struct{}
+
+func (stringCodec) write(w Writer, v reflect.Value) error {
+	return String(v.String()).WriteTo(w)
+}
+
+func (stringCodec) read(r Reader, v reflect.Value) error {
+	var s String
+	if err := s.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.SetString(string(s))
+	return nil
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) write(w Writer, v reflect.Value) error {
+	return ByteSlice(v.Bytes()).WriteTo(w)
+}
+
+func (bytesCodec) read(r Reader, v reflect.Value) error {
+	bs := ByteSlice(v.Bytes())
+	if err := bs.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.SetBytes(bs)
+	return nil
+}
+
+type timeCodec struct{}
+
+func (timeCodec) write(w Writer, v reflect.Value) error {
+	return Time(v.Interface().(time.Time)).WriteTo(w)
+}
+
+func (timeCodec) read(r Reader, v reflect.Value) error {
+	var t Time
+	if err := t.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(time.Time(t)))
+	return nil
+}
+
+// basicCodec returns the codec of a tag encoding.
+func basicCodec(tp reflect.Type, enc string) (codec, error) {
+	mismatch := func() (codec, error) {
+		return nil, errorsp.NewWithStacks("encoding %q cannot be used for type %v", enc, tp)
+	}
+	if ie, ok := intEncodings[enc]; ok {
+		if !isIntKind(tp.Kind()) && !isUintKind(tp.Kind()) {
+			return mismatch()
+		}
+		return intCodec{ie}, nil
+	}
+	switch enc {
+	case "float32", "float64":
+		if tp.Kind() != reflect.Float32 && tp.Kind() != reflect.Float64 {
+			return mismatch()
+		}
+		if enc == "float32" {
+			return float32Codec{}, nil
+		}
+		return float64Codec{}, nil
+	case "bool":
+		if tp.Kind() != reflect.Bool {
+			return mismatch()
+		}
+		return boolCodec{}, nil
+	case "string":
+		if tp.Kind() != reflect.String {
+			return mismatch()
+		}
+		return stringCodec{}, nil
+	case "bytes":
+		if tp.Kind() != reflect.Slice || tp.Elem().Kind() != reflect.Uint8 {
+			return mismatch()
+		}
+		return bytesCodec{}, nil
+	case "time":
+		if tp != timeType {
+			return mismatch()
+		}
+		return timeCodec{}, nil
+	}
+	return nil, errorsp.NewWithStacks("unknown encoding %q, available: %s", enc, strings.Join(encodingNames(), " "))
+}
+
+func encodingNames() []string {
+	names := []string{"float32", "float64", "bool", "string", "bytes", "time"}
+	for name := range intEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sophierCodec is the codec of types whose pointers implement Sophier.
+type sophierCodec struct{}
+
+func (sophierCodec) write(w Writer, v reflect.Value) error {
+	if !v.CanAddr() {
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		v = cp
+	}
+	return v.Addr().Interface().(Sophier).WriteTo(w)
+}
+
+func (sophierCodec) read(r Reader, v reflect.Value) error {
+	return v.Addr().Interface().(Sophier).ReadFrom(r, UNKNOWN_LEN)
+}
+
+// sliceCodec serializes a slice as a VInt of the length and the elements.
+type sliceCodec struct {
+	el codec
+}
+
+func (c sliceCodec) write(w Writer, v reflect.Value) error {
+	if err := VInt(v.Len()).WriteTo(w); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := c.el.write(w, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c sliceCodec) read(r Reader, v reflect.Value) error {
+	var n VInt
+	if err := n.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := DecodeLimits.CheckCount(int(n)); err != nil {
+		return err
+	}
+	if v.Cap() >= int(n) {
+		v.SetLen(int(n))
+	} else {
+		size := min(int(n), maxPrealloc)
+		v.Set(reflect.MakeSlice(v.Type(), size, size))
+	}
+	for i := 0; i < int(n); i++ {
+		if i == v.Len() {
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		}
+		if err := c.el.read(r, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapCodec serializes a map as a VInt of the length and the entries in the
+// order of the keys.
+type mapCodec struct {
+	key, val codec
+}
+
+func lessValue(a, b reflect.Value) bool {
+	switch {
+	case isIntKind(a.Kind()):
+		return a.Int() < b.Int()
+	case isUintKind(a.Kind()):
+		return a.Uint() < b.Uint()
+	case a.Kind() == reflect.Float32 || a.Kind() == reflect.Float64:
+		return a.Float() < b.Float()
+	}
+	return a.String() < b.String()
+}
+
+func (c mapCodec) write(w Writer, v reflect.Value) error {
+	if err := VInt(v.Len()).WriteTo(w); err != nil {
+		return err
+	}
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessValue(keys[i], keys[j])
+	})
+	for _, k := range keys {
+		if err := c.key.write(w, k); err != nil {
+			return err
+		}
+		if err := c.val.write(w, v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c mapCodec) read(r Reader, v reflect.Value) error {
+	var n VInt
+	if err := n.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if err := DecodeLimits.CheckCount(int(n)); err != nil {
+		return err
+	}
+	tp := v.Type()
+	v.Set(reflect.MakeMapWithSize(tp, min(int(n), maxPrealloc)))
+	for i := 0; i < int(n); i++ {
+		k := reflect.New(tp.Key()).Elem()
+		if err := c.key.read(r, k); err != nil {
+			return err
+		}
+		e := reflect.New(tp.Elem()).Elem()
+		if err := c.val.read(r, e); err != nil {
+			return err
+		}
+		v.SetMapIndex(k, e)
+	}
+	return nil
+}
+
+// ptrCodec serializes a pointer as a Bool of whether it is non-nil, followed
+// by the element if it is.
+type ptrCodec struct {
+	el codec
+}
+
+func (c ptrCodec) write(w Writer, v reflect.Value) error {
+	if v.IsNil() {
+		return Bool(false).WriteTo(w)
+	}
+	if err := Bool(true).WriteTo(w); err != nil {
+		return err
+	}
+	return c.el.write(w, v.Elem())
+}
+
+func (c ptrCodec) read(r Reader, v reflect.Value) error {
+	var valid Bool
+	if err := valid.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if !valid {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return c.el.read(r, v.Elem())
+}
@@ -0,0 +1,153 @@
+package sophie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+type reflectInner struct {
+	Key string
+	Val int `sophie:"int32"`
+}
+
+type reflectRecord struct {
+	Name    string
+	Count   int
+	Small   int16
+	Delta   int64  `sophie:"svint"`
+	Size    uint32 `sophie:"vint"`
+	Score   float32
+	Valid   bool
+	Created time.Time
+	Data    []byte
+	Tags    []string
+	IDs     []int `sophie:"int32"`
+	Inner   reflectInner
+	Inners  []reflectInner
+	Index   map[string]int
+	Next    *reflectRecord
+	Str     String
+	Ignored int `sophie:"-"`
+	hidden  int
+}
+
+func TestStructSophier(t *testing.T) {
+	a := reflectRecord{
+		Name:    "abc",
+		Count:   -3,
+		Small:   7,
+		Delta:   -5,
+		Size:    300,
+		Score:   1.5,
+		Valid:   true,
+		Created: time.Unix(1400000000, 5).UTC(),
+		Data:    []byte("data"),
+		Tags:    []string{"x", "y"},
+		IDs:     []int{1, -2},
+		Inner:   reflectInner{Key: "k", Val: 10},
+		Inners:  []reflectInner{{Key: "a", Val: 1}},
+		Index:   map[string]int{"b": 2, "a": 1},
+		Next:    &reflectRecord{Name: "next", Created: time.Unix(0, 0).UTC()},
+		Str:     "str",
+		Ignored: 1,
+		hidden:  2,
+	}
+	sa, err := NewStructSophier(&a)
+	assert.NoErrorOrDie(t, err)
+	var buf bytesp.Slice
+	assert.NoError(t, sa.WriteTo(&buf))
+
+	// Same bytes as hand-written code.
+	var exp bytesp.Slice
+	writeAll := func(ss ...SophieWriter) {
+		for _, s := range ss {
+			assert.NoError(t, s.WriteTo(&exp))
+		}
+	}
+	writeAll(String("abc"), VInt(-3), Int16(7), SVInt64(-5), VInt(300), Float32(1.5), Bool(true),
+		Time(a.Created), ByteSlice("data"))
+	assert.NoError(t, WriteStringSlice(&exp, []string{"x", "y"}))
+	writeAll(VInt(2), Int32(1), Int32(-2))
+	writeAll(String("k"), Int32(10))
+	writeAll(VInt(1), String("a"), Int32(1))
+	writeAll(VInt(2), String("a"), VInt(1), String("b"), VInt(2))
+	writeAll(Bool(true), String("next"), VInt(0), Int16(0), SVInt64(0), VInt(0), Float32(0), Bool(false),
+		Time(time.Unix(0, 0).UTC()), ByteSlice(nil), VInt(0), VInt(0), String(""), Int32(0), VInt(0), VInt(0),
+		Bool(false), String(""))
+	writeAll(String("str"))
+	assert.Equal(t, "buf", buf, exp)
+
+	var b reflectRecord
+	sb, err := NewStructSophier(&b)
+	assert.NoErrorOrDie(t, err)
+	assert.NoError(t, sb.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "len(buf)", len(buf), 0)
+	a.Ignored, a.hidden = 0, 0
+	a.Next.Index = map[string]int{}
+	assert.Equal(t, "*b.Next", *b.Next, *a.Next)
+	a.Next = b.Next
+	assert.Equal(t, "b", b, a)
+
+	// Reads into a used struct
+	a = reflectRecord{Name: "def", Created: time.Unix(0, 0).UTC()}
+	assert.NoError(t, sa.WriteTo(&buf))
+	assert.NoError(t, sb.ReadFrom(&buf, len(buf)))
+	assert.Equal(t, "b.Name", b.Name, "def")
+	assert.Equal(t, "len(b.Tags)", len(b.Tags), 0)
+	assert.Equal(t, "len(b.Index)", len(b.Index), 0)
+	assert.Equal(t, "b.Next", b.Next, (*reflectRecord)(nil))
+	assert.Equal(t, "b.Val()", sb.Val(), interface{}(&b))
+}
+
+func TestStructSophierF(t *testing.T) {
+	newF, err := NewStructSophierF(&reflectInner{})
+	assert.NoErrorOrDie(t, err)
+	s1, s2 := newF(), newF()
+	assert.True(t, "s1 != s2", s1.(*StructSophier).Val() != s2.(*StructSophier).Val())
+
+	*s1.(*StructSophier).Val().(*reflectInner) = reflectInner{Key: "a", Val: 1}
+	readWrite(t, s1, s2, 6)
+	assert.Equal(t, "s2", s2.(*StructSophier).Val(), s1.(*StructSophier).Val())
+
+	_, err = NewStructSophierF(reflectInner{})
+	assert.Error(t, err)
+}
+
+func TestStructSophier_Errors(t *testing.T) {
+	_, err := NewStructSophier(reflectInner{})
+	assert.Error(t, err)
+	_, err = NewStructSophier((*reflectInner)(nil))
+	assert.Error(t, err)
+
+	_, err = NewStructSophier(&struct {
+		A string `sophie:"vint"`
+	}{})
+	assert.Error(t, err)
+	_, err = NewStructSophier(&struct {
+		A int `sophie:"unknown"`
+	}{})
+	assert.Error(t, err)
+	_, err = NewStructSophier(&struct {
+		M map[[2]int]int
+	}{})
+	assert.Error(t, err)
+
+	var r reflectInner
+	s, err := NewStructSophier(&r)
+	assert.NoErrorOrDie(t, err)
+	bs := bytesp.Slice("\x01k\x01")
+	assert.Error(t, s.ReadFrom(&bs, len(bs)))
+
+	var sl struct{ S []int }
+	s, err = NewStructSophier(&sl)
+	assert.NoErrorOrDie(t, err)
+	bs = bytesp.Slice("\x7f")
+	lim := DecodeLimits
+	DecodeLimits.MaxCount = 10
+	defer func() { DecodeLimits = lim }()
+	assert.Equal(t, "ReadFrom", errorsp.Cause(s.ReadFrom(&bs, len(bs))), ErrBadFormat)
+}
@@ -0,0 +1,37 @@
+package sophie
+
+// Sizer is an optional interface of SophieWriters returning the number of
+// bytes WriteTo writes without writing them. It is implemented by the types
+// in sophie and its sub packages, except the ones whose size is as expensive
+// as writing, e.g. JSON. kv.Writer uses it to write the length prefix before
+// writing a key or value directly, instead of through a buffer.
+type Sizer interface {
+	EncodedLen() int
+}
+
+// countingWriter is a Writer counting the bytes written and discarding them.
+type countingWriter int
+
+// io.Writer interface
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+// io.ByteWriter interface
+func (c *countingWriter) WriteByte(byte) error {
+	*c++
+	return nil
+}
+
+// EncodedLen returns the number of bytes s.WriteTo writes. If s does not
+// implement Sizer, it is written to a Writer discarding the bytes, and the
+// bytes written before an error, if any, are counted.
+func EncodedLen(s SophieWriter) int {
+	if sz, ok := s.(Sizer); ok {
+		return sz.EncodedLen()
+	}
+	var c countingWriter
+	s.WriteTo(&c)
+	return int(c)
+}
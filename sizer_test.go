@@ -0,0 +1,49 @@
+package sophie
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestEncodedLen(t *testing.T) {
+	tm := time.Date(2014, 5, 13, 16, 53, 20, 5, time.FixedZone("", 3600))
+	reg := NewRegistry()
+	assert.NoError(t, reg.Register(300, NewString))
+	s := String("abc")
+	for _, sw := range []SophieWriter{
+		Int32(-1), VInt(0), VInt(300), VInt(-1), RawVInt(0), RawVInt(256), RawVInt(-1),
+		ByteSlice(nil), ByteSlice(make([]byte, 200)), RawByteSlice("ab"), String("abc"), RawString("abc"),
+		NULL,
+		VInt64(math.MinInt64), UVInt64(math.MaxUint64), SVInt(-65), SVInt64(64),
+		RawVInt64(-1), RawUVInt64(1 << 20), RawSVInt64(-129),
+		Int8(1), Int16(1), Int64(1), Uint8(1), Uint16(1), Uint32(1), Uint64(1), Float32(1), Float64(1), Bool(true),
+		UnixSeconds(tm), UnixMillis(tm), UnixNanos(tm), ZonedUnixSeconds(tm), ZonedUnixMillis(tm), ZonedUnixNanos(tm),
+		DeltaVIntSlice(nil), DeltaVIntSlice{-3, 100, 1000, 1 << 40},
+		PackedIntSlice(nil), PackedIntSlice{3}, PackedIntSlice{1, 5, 100, 7}, PackedIntSlice{math.MinInt64, math.MaxInt64},
+		Bitset(nil), Bitset{1, 0, 3, 0, 0},
+		Slice[String, *String]{"a", "bcd"},
+		Map[String, VInt, *String, *VInt]{"a": 1, "b": 300},
+		Optional[VInt, *VInt]{}, Optional[VInt, *VInt]{Val: 300, Valid: true},
+		Pair[String, Time, *String, *Time]{First: "a", Second: Time(tm)},
+		Union{Registry: reg, Val: &s},
+	} {
+		var buf bytesp.Slice
+		assert.NoError(t, sw.WriteTo(&buf))
+		assert.Equal(t, fmt.Sprintf("EncodedLen(%T(%v))", sw, sw), sw.(Sizer).EncodedLen(), len(buf))
+	}
+}
+
+func TestEncodedLen_NotSizer(t *testing.T) {
+	j := JSON[[]int]{Val: []int{1, 2}}
+	_, ok := interface{}(j).(Sizer)
+	assert.False(t, "ok", ok)
+	assert.Equal(t, "EncodedLen(j)", EncodedLen(j), 1+5)
+
+	_, ok = interface{}(Time{}).(Sizer)
+	assert.False(t, "Time is Sizer", ok)
+}
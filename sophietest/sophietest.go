@@ -0,0 +1,243 @@
+/*
+Package sophietest provides utilities for testing Sophier implementations,
+e.g.:
+
+	func TestRecord(t *testing.T) {
+		values := []sophie.SophieWriter{Record{}, Record{Name: "abc"}}
+		sophietest.RoundTrip(t, NewRecord, values...)
+		sophietest.Truncated(t, NewRecord, values...)
+		sophietest.Golden(t, "testdata/record.golden", values...)
+	}
+
+	func FuzzRecord(f *testing.F) {
+		sophietest.Fuzz(f, NewRecord, Record{Name: "abc"})
+	}
+
+Values are compared by their serialized bytes, i.e. a value read back is
+considered equal if it is written as the same bytes.
+*/
+package sophietest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+
+	"github.com/daviddengcn/sophie"
+)
+
+var update = flag.Bool("sophietest.update", false, "update the golden files checked by sophietest.Golden")
+
+func encode(s sophie.SophieWriter) ([]byte, error) {
+	var buf bytesp.Slice
+	err := s.WriteTo(&buf)
+	return buf, err
+}
+
+// The bytes appended after the encoded bytes to check that a ReadFrom does
+// not read beyond the value.
+const sentinel = "\xa5\x5a"
+
+// readBack reads encoded from a buffer followed by the sentinel with length l,
+// and returns the bytes of the value read written again.
+func readBack(newF func() sophie.Sophier, encoded []byte, l int) ([]byte, error) {
+	buf := bytesp.Slice(string(encoded) + sentinel)
+	s := newF()
+	if err := s.ReadFrom(&buf, l); err != nil {
+		return nil, err
+	}
+	if string(buf) != sentinel {
+		return nil, fmt.Errorf("%d bytes consumed, expected %d", len(encoded)+len(sentinel)-len(buf), len(encoded))
+	}
+	return encode(s)
+}
+
+func roundTrip(t testing.TB, newF func() sophie.Sophier, lens bool, values []sophie.SophieWriter) {
+	t.Helper()
+	for _, v := range values {
+		encoded, err := encode(v)
+		if err != nil {
+			t.Errorf("WriteTo of %s failed: %v", sophie.Text(v), err)
+			continue
+		}
+		if sz, ok := v.(sophie.Sizer); ok && sz.EncodedLen() != len(encoded) {
+			t.Errorf("EncodedLen of %s is %d, but %d bytes were written", sophie.Text(v), sz.EncodedLen(), len(encoded))
+		}
+		ls := []int{len(encoded)}
+		if !lens {
+			ls = append(ls, sophie.UNKNOWN_LEN)
+		}
+		for _, l := range ls {
+			back, err := readBack(newF, encoded, l)
+			if err != nil {
+				t.Errorf("reading %s with l = %d failed: %v", sophie.Text(v), l, err)
+				continue
+			}
+			if !bytes.Equal(back, encoded) {
+				t.Errorf("%s read with l = %d is written as %q, expected %q", sophie.Text(v), l, back, encoded)
+			}
+		}
+	}
+}
+
+// RoundTrip checks that each value written can be read back, with both the
+// explicit length and UNKNOWN_LEN, into a Sophier returned by newF, which is
+// written as the same bytes. It also checks that exactly the written bytes
+// are consumed, and that the result of EncodedLen, if implemented, matches.
+func RoundTrip(t testing.TB, newF func() sophie.Sophier, values ...sophie.SophieWriter) {
+	t.Helper()
+	roundTrip(t, newF, false, values)
+}
+
+// RoundTripWithLen is similar to RoundTrip but only reads with the explicit
+// length. It is for Sophiers which cannot be read with UNKNOWN_LEN, e.g.
+// sophie.RawString.
+func RoundTripWithLen(t testing.TB, newF func() sophie.Sophier, values ...sophie.SophieWriter) {
+	t.Helper()
+	roundTrip(t, newF, true, values)
+}
+
+func isTruncatedErr(err error) bool {
+	switch errorsp.Cause(err) {
+	case io.EOF, io.ErrUnexpectedEOF, sophie.ErrBadFormat:
+		return true
+	}
+	return false
+}
+
+// Truncated checks that reading any proper prefix of the bytes of each value,
+// with UNKNOWN_LEN or with the length of the whole value, fails with io.EOF,
+// io.ErrUnexpectedEOF or sophie.ErrBadFormat.
+func Truncated(t testing.TB, newF func() sophie.Sophier, values ...sophie.SophieWriter) {
+	t.Helper()
+	truncated(t, newF, false, values)
+}
+
+// TruncatedWithLen is similar to Truncated but only reads with the length of
+// the whole value, for Sophiers which cannot be read with UNKNOWN_LEN.
+func TruncatedWithLen(t testing.TB, newF func() sophie.Sophier, values ...sophie.SophieWriter) {
+	t.Helper()
+	truncated(t, newF, true, values)
+}
+
+func truncated(t testing.TB, newF func() sophie.Sophier, lens bool, values []sophie.SophieWriter) {
+	t.Helper()
+	for _, v := range values {
+		encoded, err := encode(v)
+		if err != nil {
+			t.Errorf("WriteTo of %s failed: %v", sophie.Text(v), err)
+			continue
+		}
+		ls := []int{len(encoded)}
+		if !lens {
+			ls = append(ls, sophie.UNKNOWN_LEN)
+		}
+		for n := 0; n < len(encoded); n++ {
+			for _, l := range ls {
+				buf := bytesp.Slice(encoded[:n:n])
+				err := newF().ReadFrom(&buf, l)
+				if !isTruncatedErr(err) {
+					t.Errorf("reading %s truncated to %d bytes with l = %d returned %v, expected an EOF or bad format error", sophie.Text(v), n, l, err)
+				}
+			}
+		}
+	}
+}
+
+// goldenText returns the text form of v in a golden file. It is only for
+// reading, so the type is used if v is not a Formatter, whose fmt.Sprint may
+// not be stable, e.g. pointers.
+func goldenText(v sophie.SophieWriter) string {
+	if _, ok := v.(sophie.Formatter); ok {
+		return sophie.Text(v)
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// Golden checks the bytes of values against a golden file, which contains a
+// line of the hex of the bytes and the text form of each value. Only the bytes
+// are compared. Run the tests with -sophietest.update to create or update the
+// golden files.
+func Golden(t testing.TB, path string, values ...sophie.SophieWriter) {
+	t.Helper()
+	var lines []string
+	for _, v := range values {
+		encoded, err := encode(v)
+		if err != nil {
+			t.Errorf("WriteTo of %s failed: %v", sophie.Text(v), err)
+			return
+		}
+		lines = append(lines, hex.EncodeToString(encoded)+"\t"+goldenText(v))
+	}
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating the directory of %s failed: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatalf("writing %s failed: %v", path, err)
+		}
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("golden file %s does not exist, run the test with -sophietest.update to create it", path)
+		} else {
+			t.Fatalf("reading %s failed: %v", path, err)
+		}
+	}
+	golden := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(golden) != len(lines) {
+		t.Errorf("%s has %d values, but %d are checked", path, len(golden), len(lines))
+		return
+	}
+	for i, line := range lines {
+		exp, act := strings.SplitN(golden[i], "\t", 2)[0], strings.SplitN(line, "\t", 2)[0]
+		if exp != act {
+			t.Errorf("%s:%d: bytes of %s changed from %s to %s", path, i+1, sophie.Text(values[i]), exp, act)
+		}
+	}
+}
+
+// Fuzz runs a fuzz test reading arbitrary bytes into Sophiers returned by
+// newF, with the bytes of seeds as the seed corpus. It checks that ReadFrom
+// never panics, and that a value successfully read can be written and read
+// back as the same bytes.
+func Fuzz(f *testing.F, newF func() sophie.Sophier, seeds ...sophie.SophieWriter) {
+	for _, s := range seeds {
+		encoded, err := encode(s)
+		if err != nil {
+			f.Fatalf("WriteTo of %s failed: %v", sophie.Text(s), err)
+		}
+		f.Add(encoded)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		buf := bytesp.Slice(data)
+		s := newF()
+		if err := s.ReadFrom(&buf, len(data)); err != nil {
+			return
+		}
+		encoded, err := encode(s)
+		if err != nil {
+			// Values read may not be valid to write, e.g. unsorted elements.
+			return
+		}
+		back, err := readBack(newF, encoded, len(encoded))
+		if err != nil {
+			t.Fatalf("reading %q written from %q failed: %v", encoded, data, err)
+		}
+		if !bytes.Equal(back, encoded) {
+			t.Fatalf("%q read from %q is written as %q", encoded, data, back)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package sophietest
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/golangplus/testing/assert"
+
+	"github.com/daviddengcn/sophie"
+)
+
+func TestSophieTypes(t *testing.T) {
+	vints := []sophie.SophieWriter{sophie.VInt(0), sophie.VInt(127), sophie.VInt(128), sophie.VInt(1 << 40)}
+	RoundTrip(t, sophie.NewVInt, vints...)
+	Truncated(t, sophie.NewVInt, vints...)
+	Golden(t, "testdata/vint.golden", vints...)
+
+	strs := []sophie.SophieWriter{sophie.String(""), sophie.String("abc"), sophie.String("a\x00b")}
+	RoundTrip(t, sophie.NewString, strs...)
+	Truncated(t, sophie.NewString, strs...)
+	Golden(t, "testdata/string.golden", strs...)
+
+	raws := []sophie.SophieWriter{sophie.RawString(""), sophie.RawString("abc")}
+	RoundTripWithLen(t, sophie.NewRawString, raws...)
+	TruncatedWithLen(t, sophie.NewRawString, raws...)
+}
+
+// recorder records the errors reported instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// Fatalf stops the goroutine as testing.T does, so it must be called in run.
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls f in a new goroutine, which may be stopped by Fatalf, and waits
+// for it.
+func (r *recorder) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
+
+// greedy reads all the remaining bytes as the value if the length is unknown.
+type greedy struct {
+	sophie.ByteSlice
+}
+
+func (g *greedy) ReadFrom(r sophie.Reader, l int) error {
+	if l != sophie.UNKNOWN_LEN {
+		return g.ByteSlice.ReadFrom(r, l)
+	}
+	g.ByteSlice = g.ByteSlice[:0]
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil
+		}
+		g.ByteSlice = append(g.ByteSlice, b)
+	}
+}
+
+func newGreedy() sophie.Sophier {
+	return &greedy{}
+}
+
+func TestRoundTrip_Failures(t *testing.T) {
+	rec := &recorder{TB: t}
+	RoundTrip(rec, newGreedy, sophie.ByteSlice("abc"))
+	assert.True(t, "errors", len(rec.errors) > 0)
+
+	// Reading a VInt as an Int32 gets different bytes.
+	rec = &recorder{TB: t}
+	RoundTrip(rec, sophie.NewInt32, sophie.VInt(1<<20))
+	assert.True(t, "errors", len(rec.errors) > 0)
+}
+
+func TestTruncated_Failures(t *testing.T) {
+	rec := &recorder{TB: t}
+	Truncated(rec, newGreedy, sophie.ByteSlice("ab"))
+	assert.True(t, "errors", len(rec.errors) > 0)
+
+	rec = &recorder{TB: t}
+	TruncatedWithLen(rec, newGreedy, sophie.ByteSlice("ab"))
+	assert.Equal(t, "errors", len(rec.errors), 0)
+}
+
+func TestGolden_Failures(t *testing.T) {
+	rec := &recorder{TB: t}
+	Golden(rec, "testdata/vint.golden", sophie.VInt(1), sophie.VInt(127), sophie.VInt(128), sophie.VInt(1<<40))
+	assert.Equal(t, "errors", rec.errors, []string{"testdata/vint.golden:1: bytes of 1 changed from 00 to 01"})
+
+	rec = &recorder{TB: t}
+	Golden(rec, "testdata/vint.golden", sophie.VInt(0))
+	assert.Equal(t, "len(errors)", len(rec.errors), 1)
+
+	rec = &recorder{TB: t}
+	rec.run(func() {
+		Golden(rec, "testdata/nonexistent.golden", sophie.VInt(0))
+	})
+	assert.Equal(t, "len(errors)", len(rec.errors), 1)
+}
+
+func FuzzVInt(f *testing.F) {
+	Fuzz(f, sophie.NewVInt, sophie.VInt(0), sophie.VInt(300))
+}
+
+func FuzzString(f *testing.F) {
+	Fuzz(f, sophie.NewString, sophie.String("abc"))
+}
@@ -0,0 +1,181 @@
+package sophie
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golangplus/errors"
+)
+
+// Formatter is an optional interface of Sophiers returning the value in a
+// JSON-like text form for debugging, e.g. in tools or error messages:
+//
+//	Int32, VInt, RawVInt              123
+//	ByteSlice, RawByteSlice           "a\x00b", quoted as a Go string
+//	String, RawString                 "abc"
+//	Null                              null
+//	Time                              "2014-05-13T16:53:20.000000005Z"
+type Formatter interface {
+	FormatText() string
+}
+
+// Parser is an optional interface of Sophiers parsing the text form returned
+// by Formatter.
+type Parser interface {
+	ParseText(text string) error
+}
+
+// Text returns the text form of v if it is a Formatter, or the result of
+// fmt.Sprint otherwise.
+func Text(v interface{}) string {
+	if f, ok := v.(Formatter); ok {
+		return f.FormatText()
+	}
+	return fmt.Sprint(v)
+}
+
+// ParseText parses text into s, which must be a Parser.
+func ParseText(s SophieReader, text string) error {
+	p, ok := s.(Parser)
+	if !ok {
+		return errorsp.NewWithStacks("%T is not a Parser", s)
+	}
+	return p.ParseText(text)
+}
+
+func parseInt(text string, bitSize int) (int64, error) {
+	v, err := strconv.ParseInt(strings.TrimSpace(text), 10, bitSize)
+	return v, errorsp.WithStacks(err)
+}
+
+func parseQuoted(text string) (string, error) {
+	s, err := strconv.Unquote(strings.TrimSpace(text))
+	if err != nil {
+		return "", errorsp.WithStacksAndMessage(err, "invalid quoted text %q", text)
+	}
+	return s, nil
+}
+
+// Formatter interface
+func (i Int32) FormatText() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+// Parser interface
+func (i *Int32) ParseText(text string) error {
+	v, err := parseInt(text, 32)
+	if err != nil {
+		return err
+	}
+	*i = Int32(v)
+	return nil
+}
+
+// Formatter interface
+func (i VInt) FormatText() string {
+	return strconv.Itoa(int(i))
+}
+
+// Parser interface
+func (i *VInt) ParseText(text string) error {
+	v, err := parseInt(text, 0)
+	if err != nil {
+		return err
+	}
+	*i = VInt(v)
+	return nil
+}
+
+// Formatter interface
+func (i RawVInt) FormatText() string {
+	return strconv.Itoa(int(i))
+}
+
+// Parser interface
+func (i *RawVInt) ParseText(text string) error {
+	return (*VInt)(i).ParseText(text)
+}
+
+// Formatter interface
+func (ba ByteSlice) FormatText() string {
+	return strconv.Quote(string(ba))
+}
+
+// Parser interface
+func (ba *ByteSlice) ParseText(text string) error {
+	s, err := parseQuoted(text)
+	if err != nil {
+		return err
+	}
+	*ba = append((*ba)[:0], s...)
+	return nil
+}
+
+// Formatter interface
+func (ba RawByteSlice) FormatText() string {
+	return strconv.Quote(string(ba))
+}
+
+// Parser interface
+func (ba *RawByteSlice) ParseText(text string) error {
+	return (*ByteSlice)(ba).ParseText(text)
+}
+
+// Formatter interface
+func (s String) FormatText() string {
+	return strconv.Quote(string(s))
+}
+
+// Parser interface
+func (s *String) ParseText(text string) error {
+	v, err := parseQuoted(text)
+	if err != nil {
+		return err
+	}
+	*s = String(v)
+	return nil
+}
+
+// Formatter interface
+func (s RawString) FormatText() string {
+	return strconv.Quote(string(s))
+}
+
+// Parser interface
+func (s *RawString) ParseText(text string) error {
+	return (*String)(s).ParseText(text)
+}
+
+// Formatter interface
+func (Null) FormatText() string {
+	return "null"
+}
+
+// Parser interface
+func (Null) ParseText(text string) error {
+	if strings.TrimSpace(text) != "null" {
+		return errorsp.NewWithStacks("invalid text %q of Null", text)
+	}
+	return nil
+}
+
+// Formatter interface
+func (t Time) FormatText() string {
+	return strconv.Quote(time.Time(t).Format(time.RFC3339Nano))
+}
+
+// Parser interface
+func (t *Time) ParseText(text string) error {
+	s, err := parseQuoted(text)
+	if err != nil {
+		return err
+	}
+	v, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return errorsp.WithStacks(err)
+	}
+	*t = Time(v)
+	return nil
+}
@@ -0,0 +1,51 @@
+package sophie
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golangplus/testing/assert"
+)
+
+func TestText(t *testing.T) {
+	tm := time.Date(2014, 5, 13, 16, 53, 20, 5, time.UTC)
+	for _, c := range []struct {
+		val    Formatter
+		text   string
+		newVal func() Sophier
+	}{
+		{Int32(-12), "-12", NewInt32},
+		{VInt(300), "300", NewVInt},
+		{RawVInt(-1), "-1", NewRawVInt},
+		{ByteSlice("a\x00\xffb"), `"a\x00\xffb"`, NewByteSlice},
+		{RawByteSlice("ab"), `"ab"`, NewRawByteSlice},
+		{String("a\"b\n"), `"a\"b\n"`, NewString},
+		{RawString("中文"), `"中文"`, NewRawString},
+		{NULL, "null", ReturnNULL},
+		{Time(tm), `"2014-05-13T16:53:20.000000005Z"`, NewTime},
+	} {
+		assert.Equal(t, fmt.Sprintf("FormatText of %T", c.val), c.val.FormatText(), c.text)
+		assert.Equal(t, fmt.Sprintf("Text of %T", c.val), Text(c.val), c.text)
+
+		s := c.newVal()
+		assert.NoError(t, ParseText(s, " "+c.text+" "))
+		assert.Equal(t, fmt.Sprintf("Text of parsed %T", c.val), Text(s), c.text)
+	}
+	// Not a Formatter
+	assert.Equal(t, "Text", Text(Int8(3)), "3")
+}
+
+func TestParseText_Errors(t *testing.T) {
+	var i32 Int32
+	assert.Error(t, ParseText(&i32, "1e10"))
+	var vi VInt
+	assert.Error(t, ParseText(&vi, "abc"))
+	var s String
+	assert.Error(t, ParseText(&s, "abc"))
+	var tm Time
+	assert.Error(t, ParseText(&tm, `"yesterday"`))
+	assert.Error(t, ParseText(NULL, "nil"))
+	var i8 Int8
+	assert.Error(t, ParseText(&i8, "1"))
+}
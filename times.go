@@ -0,0 +1,263 @@
+package sophie
+
+import (
+	"time"
+
+	"github.com/golangplus/errors"
+)
+
+// The compact time types below are much smaller than Time, which stores the
+// output of time.Time.MarshalBinary. The Unix* types are normalized to UTC,
+// i.e. the location is dropped when writing and the time read is always in
+// UTC. The ZonedUnix* types also store the zone offset, and the time read is
+// in a fixed zone of that offset (the zone name is not kept).
+//
+// Since the zigzag varints do not sort in the order of the values, use the
+// types in package keys for mr keys.
+
+// UnixSeconds is a time.Time, and *UnixSeconds implements Sophier interface.
+// It is serialized as an SVInt64 of the Unix seconds. The sub-second part is
+// truncated.
+type UnixSeconds time.Time
+
+// Returns a new instance of *UnixSeconds as a Sophier
+func NewUnixSeconds() Sophier {
+	return new(UnixSeconds)
+}
+
+// SophieWriter interface
+func (t UnixSeconds) WriteTo(w Writer) error {
+	return SVInt64(time.Time(t).Unix()).WriteTo(w)
+}
+
+// Sizer interface
+func (t UnixSeconds) EncodedLen() int {
+	return SVInt64(time.Time(t).Unix()).EncodedLen()
+}
+
+// SophieReader interface
+func (t *UnixSeconds) ReadFrom(r Reader, l int) error {
+	var s SVInt64
+	if err := s.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	*t = UnixSeconds(time.Unix(int64(s), 0).UTC())
+	return nil
+}
+
+func (t *UnixSeconds) Val() time.Time {
+	return time.Time(*t)
+}
+
+// UnixMillis is a time.Time, and *UnixMillis implements Sophier interface. It
+// is serialized as an SVInt64 of the Unix milliseconds. The sub-millisecond
+// part is truncated.
+type UnixMillis time.Time
+
+// Returns a new instance of *UnixMillis as a Sophier
+func NewUnixMillis() Sophier {
+	return new(UnixMillis)
+}
+
+// SophieWriter interface
+func (t UnixMillis) WriteTo(w Writer) error {
+	return SVInt64(time.Time(t).UnixMilli()).WriteTo(w)
+}
+
+// Sizer interface
+func (t UnixMillis) EncodedLen() int {
+	return SVInt64(time.Time(t).UnixMilli()).EncodedLen()
+}
+
+// SophieReader interface
+func (t *UnixMillis) ReadFrom(r Reader, l int) error {
+	var ms SVInt64
+	if err := ms.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	*t = UnixMillis(time.UnixMilli(int64(ms)).UTC())
+	return nil
+}
+
+func (t *UnixMillis) Val() time.Time {
+	return time.Time(*t)
+}
+
+// UnixNanos is a time.Time, and *UnixNanos implements Sophier interface. It is
+// serialized as an SVInt64 of the Unix nanoseconds. Only the times between
+// years 1678 and 2262 can be represented.
+type UnixNanos time.Time
+
+// Returns a new instance of *UnixNanos as a Sophier
+func NewUnixNanos() Sophier {
+	return new(UnixNanos)
+}
+
+// SophieWriter interface
+func (t UnixNanos) WriteTo(w Writer) error {
+	return SVInt64(time.Time(t).UnixNano()).WriteTo(w)
+}
+
+// Sizer interface
+func (t UnixNanos) EncodedLen() int {
+	return SVInt64(time.Time(t).UnixNano()).EncodedLen()
+}
+
+// SophieReader interface
+func (t *UnixNanos) ReadFrom(r Reader, l int) error {
+	var ns SVInt64
+	if err := ns.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	*t = UnixNanos(time.Unix(0, int64(ns)).UTC())
+	return nil
+}
+
+func (t *UnixNanos) Val() time.Time {
+	return time.Time(*t)
+}
+
+// The maximum absolute value of a zone offset in seconds.
+const maxZoneOffset = 24 * 60 * 60
+
+func writeZone(w Writer, t time.Time) error {
+	_, offset := t.Zone()
+	return SVInt(offset).WriteTo(w)
+}
+
+func readZone(r Reader) (*time.Location, error) {
+	var offset SVInt
+	if err := offset.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return nil, err
+	}
+	if offset < -maxZoneOffset || offset > maxZoneOffset {
+		return nil, errorsp.WithStacksAndMessage(ErrBadFormat, "zone offset %d", offset)
+	}
+	if offset == 0 {
+		return time.UTC, nil
+	}
+	return time.FixedZone("", int(offset)), nil
+}
+
+// ZonedUnixSeconds is a time.Time, and *ZonedUnixSeconds implements Sophier
+// interface. It is serialized as a UnixSeconds followed by an SVInt of the
+// zone offset in seconds.
+type ZonedUnixSeconds time.Time
+
+// Returns a new instance of *ZonedUnixSeconds as a Sophier
+func NewZonedUnixSeconds() Sophier {
+	return new(ZonedUnixSeconds)
+}
+
+// SophieWriter interface
+func (t ZonedUnixSeconds) WriteTo(w Writer) error {
+	if err := UnixSeconds(t).WriteTo(w); err != nil {
+		return err
+	}
+	return writeZone(w, time.Time(t))
+}
+
+// Sizer interface
+func (t ZonedUnixSeconds) EncodedLen() int {
+	_, offset := time.Time(t).Zone()
+	return UnixSeconds(t).EncodedLen() + SVInt(offset).EncodedLen()
+}
+
+// SophieReader interface
+func (t *ZonedUnixSeconds) ReadFrom(r Reader, l int) error {
+	if err := (*UnixSeconds)(t).ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	loc, err := readZone(r)
+	if err != nil {
+		return err
+	}
+	*t = ZonedUnixSeconds(time.Time(*t).In(loc))
+	return nil
+}
+
+func (t *ZonedUnixSeconds) Val() time.Time {
+	return time.Time(*t)
+}
+
+// ZonedUnixMillis is a time.Time, and *ZonedUnixMillis implements Sophier
+// interface. It is serialized as a UnixMillis followed by an SVInt of the zone
+// offset in seconds.
+type ZonedUnixMillis time.Time
+
+// Returns a new instance of *ZonedUnixMillis as a Sophier
+func NewZonedUnixMillis() Sophier {
+	return new(ZonedUnixMillis)
+}
+
+// SophieWriter interface
+func (t ZonedUnixMillis) WriteTo(w Writer) error {
+	if err := UnixMillis(t).WriteTo(w); err != nil {
+		return err
+	}
+	return writeZone(w, time.Time(t))
+}
+
+// Sizer interface
+func (t ZonedUnixMillis) EncodedLen() int {
+	_, offset := time.Time(t).Zone()
+	return UnixMillis(t).EncodedLen() + SVInt(offset).EncodedLen()
+}
+
+// SophieReader interface
+func (t *ZonedUnixMillis) ReadFrom(r Reader, l int) error {
+	if err := (*UnixMillis)(t).ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	loc, err := readZone(r)
+	if err != nil {
+		return err
+	}
+	*t = ZonedUnixMillis(time.Time(*t).In(loc))
+	return nil
+}
+
+func (t *ZonedUnixMillis) Val() time.Time {
+	return time.Time(*t)
+}
+
+// ZonedUnixNanos is a time.Time, and *ZonedUnixNanos implements Sophier
+// interface. It is serialized as a UnixNanos followed by an SVInt of the zone
+// offset in seconds.
+type ZonedUnixNanos time.Time
+
+// Returns a new instance of *ZonedUnixNanos as a Sophier
+func NewZonedUnixNanos() Sophier {
+	return new(ZonedUnixNanos)
+}
+
+// SophieWriter interface
+func (t ZonedUnixNanos) WriteTo(w Writer) error {
+	if err := UnixNanos(t).WriteTo(w); err != nil {
+		return err
+	}
+	return writeZone(w, time.Time(t))
+}
+
+// Sizer interface
+func (t ZonedUnixNanos) EncodedLen() int {
+	_, offset := time.Time(t).Zone()
+	return UnixNanos(t).EncodedLen() + SVInt(offset).EncodedLen()
+}
+
+// SophieReader interface
+func (t *ZonedUnixNanos) ReadFrom(r Reader, l int) error {
+	if err := (*UnixNanos)(t).ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	loc, err := readZone(r)
+	if err != nil {
+		return err
+	}
+	*t = ZonedUnixNanos(time.Time(*t).In(loc))
+	return nil
+}
+
+func (t *ZonedUnixNanos) Val() time.Time {
+	return time.Time(*t)
+}
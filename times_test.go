@@ -0,0 +1,60 @@
+package sophie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestUnixTimes(t *testing.T) {
+	tm := time.Date(2014, 5, 13, 16, 53, 20, 123456789, time.FixedZone("X", -7*3600))
+
+	sa, sb := UnixSeconds(tm), UnixSeconds{}
+	readWrite(t, &sa, &sb, 5)
+	assert.Equal(t, "sb", sb.Val(), time.Unix(tm.Unix(), 0).UTC())
+
+	msa, msb := UnixMillis(tm), UnixMillis{}
+	readWrite(t, &msa, &msb, 6)
+	assert.Equal(t, "msb", msb.Val(), tm.Truncate(time.Millisecond).UTC())
+
+	nsa, nsb := UnixNanos(tm), UnixNanos{}
+	readWrite(t, &nsa, &nsb, 9)
+	assert.Equal(t, "nsb", nsb.Val(), tm.UTC())
+
+	// Times before the epoch
+	old := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	sa = UnixSeconds(old)
+	readWrite(t, &sa, &sb, 5)
+	assert.Equal(t, "sb", sb.Val(), old)
+
+	// Much smaller than Time
+	var buf bytesp.Slice
+	assert.NoError(t, Time(tm).WriteTo(&buf))
+	assert.True(t, "len(buf) > 9", len(buf) > 9)
+}
+
+func TestZonedUnixTimes(t *testing.T) {
+	tm := time.Date(2014, 5, 13, 16, 53, 20, 123456789, time.FixedZone("X", -7*3600))
+
+	sa, sb := ZonedUnixSeconds(tm), ZonedUnixSeconds{}
+	readWrite(t, &sa, &sb, 5+3)
+	assert.True(t, "sb.Equal", sb.Val().Equal(tm.Truncate(time.Second)))
+	_, offset := sb.Val().Zone()
+	assert.Equal(t, "offset", offset, -7*3600)
+
+	msa, msb := ZonedUnixMillis(tm), ZonedUnixMillis{}
+	readWrite(t, &msa, &msb, 6+3)
+	assert.True(t, "msb.Equal", msb.Val().Equal(tm.Truncate(time.Millisecond)))
+	assert.Equal(t, "msb.String()", msb.Val().String(), "2014-05-13 16:53:20.123 -0700 -0700")
+
+	nsa, nsb := ZonedUnixNanos(tm.UTC()), ZonedUnixNanos{}
+	readWrite(t, &nsa, &nsb, 9+1)
+	assert.Equal(t, "nsb", nsb.Val(), tm.UTC())
+
+	// Bad zone offset
+	buf := bytesp.Slice("\x00\xff\xff\xff\x0f")
+	assert.Equal(t, "ReadFrom", errorsp.Cause(sb.ReadFrom(&buf, len(buf))), ErrBadFormat)
+}
@@ -0,0 +1,120 @@
+package sophie
+
+import (
+	"reflect"
+
+	"github.com/golangplus/errors"
+)
+
+// Registry maps small non-negative type IDs to factories of Sophiers. It is
+// used by Union to write/read values of different types in a single stream,
+// e.g. records of different tables as the inputs of a join.
+//
+// Types are typically registered at initialization. Registering is not safe
+// to be called concurrently with others.
+type Registry struct {
+	factories map[int]func() Sophier
+	ids       map[reflect.Type]int
+}
+
+// NewRegistry returns an empty *Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[int]func() Sophier),
+		ids:       make(map[reflect.Type]int),
+	}
+}
+
+// Register registers a factory with a type ID. The type of the Sophiers
+// returned by newF identifies the values when writing, so an ID or a type can
+// be registered only once.
+func (reg *Registry) Register(id int, newF func() Sophier) error {
+	if id < 0 {
+		return errorsp.NewWithStacks("negative type ID %d", id)
+	}
+	if _, ok := reg.factories[id]; ok {
+		return errorsp.NewWithStacks("type ID %d has been registered", id)
+	}
+	tp := reflect.TypeOf(newF())
+	if old, ok := reg.ids[tp]; ok {
+		return errorsp.NewWithStacks("type %v has been registered with ID %d", tp, old)
+	}
+	reg.factories[id] = newF
+	reg.ids[tp] = id
+	return nil
+}
+
+// New returns a new Sophier of the type registered with the ID.
+func (reg *Registry) New(id int) (Sophier, error) {
+	newF, ok := reg.factories[id]
+	if !ok {
+		return nil, errorsp.WithStacksAndMessage(ErrBadFormat, "unregistered type ID %d", id)
+	}
+	return newF(), nil
+}
+
+// ID returns the type ID of a Sophier. ok is false if its type is not
+// registered.
+func (reg *Registry) ID(s Sophier) (id int, ok bool) {
+	id, ok = reg.ids[reflect.TypeOf(s)]
+	return id, ok
+}
+
+// *Union implements Sophier interface for a value of any type registered in
+// the Registry. It is serialized as a VInt of the type ID followed by the
+// value.
+type Union struct {
+	Registry *Registry
+	Val      Sophier
+}
+
+// NewUnionF returns a factory func creating *Unions with a Registry. It can
+// be used as the NewKeyF/NewValF of mr jobs or kv readers.
+func NewUnionF(reg *Registry) func() Sophier {
+	return func() Sophier {
+		return &Union{Registry: reg}
+	}
+}
+
+// SophieWriter interface
+func (u Union) WriteTo(w Writer) error {
+	id, ok := u.Registry.ID(u.Val)
+	if !ok {
+		return errorsp.NewWithStacks("type %T is not registered", u.Val)
+	}
+	if err := VInt(id).WriteTo(w); err != nil {
+		return err
+	}
+	return u.Val.WriteTo(w)
+}
+
+// Sizer interface. It is 0 if the type of Val is not registered, as WriteTo
+// writes nothing but returns an error.
+func (u Union) EncodedLen() int {
+	id, ok := u.Registry.ID(u.Val)
+	if !ok {
+		return 0
+	}
+	return uvarintLen(uint64(id)) + EncodedLen(u.Val)
+}
+
+// SophieReader interface. The current Val is reused if it is of the type read.
+func (u *Union) ReadFrom(r Reader, l int) error {
+	var id VInt
+	if err := id.ReadFrom(r, UNKNOWN_LEN); err != nil {
+		return err
+	}
+	if cur, ok := u.Registry.ID(u.Val); !ok || cur != int(id) {
+		val, err := u.Registry.New(int(id))
+		if err != nil {
+			return err
+		}
+		u.Val = val
+	}
+	if l != UNKNOWN_LEN {
+		if l -= uvarintLen(uint64(id)); l < 0 {
+			return errorsp.WithStacksAndMessage(ErrBadFormat, "l = %d", l)
+		}
+	}
+	return u.Val.ReadFrom(r, l)
+}
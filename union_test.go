@@ -0,0 +1,71 @@
+package sophie
+
+import (
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	assert.NoError(t, reg.Register(0, NewString))
+	assert.NoError(t, reg.Register(200, NewVInt))
+	assert.Error(t, reg.Register(0, NewRawString))
+	assert.Error(t, reg.Register(1, NewString))
+	assert.Error(t, reg.Register(-1, NewRawString))
+
+	s, err := reg.New(200)
+	assert.NoError(t, err)
+	_, ok := s.(*VInt)
+	assert.True(t, "ok", ok)
+
+	id, ok := reg.ID(s)
+	assert.True(t, "ok", ok)
+	assert.Equal(t, "id", id, 200)
+
+	_, ok = reg.ID(new(RawString))
+	assert.False(t, "ok", ok)
+
+	_, err = reg.New(1)
+	assert.Equal(t, "err", errorsp.Cause(err), ErrBadFormat)
+}
+
+func TestUnion(t *testing.T) {
+	reg := NewRegistry()
+	assert.NoError(t, reg.Register(1, NewString))
+	assert.NoError(t, reg.Register(300, NewRawString))
+
+	ua := Union{Registry: reg}
+	ub := NewUnionF(reg)().(*Union)
+
+	s := String("abc")
+	ua.Val = &s
+	readWrite(t, &ua, ub, 1+4)
+	assert.Equal(t, "ub.Val", ub.Val, Sophier(&s))
+
+	// The Val of the same type is reused.
+	val := ub.Val
+	s = "de"
+	readWrite(t, &ua, ub, 1+3)
+	assert.True(t, "ub.Val == val", ub.Val == val)
+	assert.Equal(t, "ub.Val", *ub.Val.(*String), String("de"))
+
+	// The known length minus the tag is passed to the value.
+	rs := RawString("xyz")
+	ua.Val = &rs
+	readWrite(t, &ua, ub, 2+3)
+	assert.Equal(t, "ub.Val", ub.Val, Sophier(&rs))
+
+	// Unregistered types
+	vi := VInt(1)
+	ua.Val = &vi
+	var buf bytesp.Slice
+	assert.Error(t, ua.WriteTo(&buf))
+	assert.Equal(t, "len(buf)", len(buf), 0)
+	assert.Equal(t, "EncodedLen", ua.EncodedLen(), 0)
+
+	buf = bytesp.Slice("\x02\x00")
+	assert.Equal(t, "ReadFrom", errorsp.Cause(ub.ReadFrom(&buf, len(buf))), ErrBadFormat)
+}
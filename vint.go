@@ -0,0 +1,367 @@
+package sophie
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/golangplus/errors"
+)
+
+// The maximum number of bytes a 64-bit vint can take.
+const maxVIntLen64 = 10
+
+// writeUVarint writes v as a vint, i.e. 7 bits per byte, lowest bits first,
+// the highest bit of each byte indicating whether more bytes follow.
+func writeUVarint(w Writer, v uint64) error {
+	var arr [maxVIntLen64]byte
+	n := 0
+	for v > 0x7f {
+		arr[n] = byte(v&0x7f) | 0x80
+		n++
+		v >>= 7
+	}
+	arr[n] = byte(v)
+	n++
+	_, err := w.Write(arr[:n])
+	return errorsp.WithStacks(err)
+}
+
+// uvarintLen returns the number of bytes writeUVarint writes for v.
+func uvarintLen(v uint64) int {
+	n := 1
+	for ; v > 0x7f; v >>= 7 {
+		n++
+	}
+	return n
+}
+
+// readUVarint reads a vint written by writeUVarint. ErrBadFormat is returned if
+// the value overflows 64 bits.
+func readUVarint(r Reader) (uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, errorsp.WithStacks(err)
+	}
+	v := uint64(b & 0x7f)
+	for n := 1; b&0x80 != 0; n++ {
+		if n == maxVIntLen64 {
+			return 0, errorsp.WithStacksAndMessage(ErrBadFormat, "vint longer than %d bytes", maxVIntLen64)
+		}
+		if b, err = r.ReadByte(); err != nil {
+			if errorsp.Cause(err) == io.EOF {
+				return 0, errorsp.WithStacks(io.ErrUnexpectedEOF)
+			}
+			return 0, errorsp.WithStacks(err)
+		}
+		if n == maxVIntLen64-1 && b > 1 {
+			return 0, errorsp.WithStacksAndMessage(ErrBadFormat, "vint overflows 64 bits")
+		}
+		v |= uint64(b&0x7f) << (7 * uint(n))
+	}
+	return v, nil
+}
+
+// writeRawUint writes the non-zero lower bytes of v, lowest byte first.
+func writeRawUint(w Writer, v uint64) error {
+	var arr [8]byte
+	n := 0
+	for v != 0 {
+		arr[n] = byte(v)
+		n++
+		v >>= 8
+	}
+	_, err := w.Write(arr[:n])
+	return errorsp.WithStacks(err)
+}
+
+// rawUintLen returns the number of bytes writeRawUint writes for v.
+func rawUintLen(v uint64) int {
+	return (bits.Len64(v) + 7) / 8
+}
+
+// readRawUint reads l bytes written by writeRawUint.
+func readRawUint(r Reader, l int) (uint64, error) {
+	if l < 0 || l > 8 {
+		return 0, errorsp.WithStacksAndMessage(ErrBadFormat, "l = %d", l)
+	}
+	var v uint64
+	for n := uint(0); l > 0; l-- {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errorsp.WithStacks(err)
+		}
+		v |= uint64(b) << n
+		n += 8
+	}
+	return v, nil
+}
+
+// zigzag maps signed integers to unsigned ones so that numbers with small
+// absolute values have small encodings: 0, -1, 1, -2, ... => 0, 1, 2, 3, ...
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// unzigzag is the reverse function of zigzag.
+func unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// *VInt64 implements Sophie interface and serializing as a vint. Negative
+// values always take 10 bytes, use SVInt64 if they are common.
+type VInt64 int64
+
+// Returns a new instance of *VInt64 as a Sophier
+func NewVInt64() Sophier {
+	return new(VInt64)
+}
+
+// SophieWriter interface
+func (i VInt64) WriteTo(w Writer) error {
+	return writeUVarint(w, uint64(i))
+}
+
+// Sizer interface
+func (i VInt64) EncodedLen() int {
+	return uvarintLen(uint64(i))
+}
+
+// SophieReader interface
+func (i *VInt64) ReadFrom(r Reader, l int) error {
+	v, err := readUVarint(r)
+	if err != nil {
+		return err
+	}
+	*i = VInt64(v)
+	return nil
+}
+
+func (i *VInt64) Val() int64 {
+	return int64(*i)
+}
+
+func (i *VInt64) String() string {
+	return fmt.Sprint(*i)
+}
+
+// *UVInt64 implements Sophie interface and serializing as a vint.
+type UVInt64 uint64
+
+// Returns a new instance of *UVInt64 as a Sophier
+func NewUVInt64() Sophier {
+	return new(UVInt64)
+}
+
+// SophieWriter interface
+func (i UVInt64) WriteTo(w Writer) error {
+	return writeUVarint(w, uint64(i))
+}
+
+// Sizer interface
+func (i UVInt64) EncodedLen() int {
+	return uvarintLen(uint64(i))
+}
+
+// SophieReader interface
+func (i *UVInt64) ReadFrom(r Reader, l int) error {
+	v, err := readUVarint(r)
+	if err != nil {
+		return err
+	}
+	*i = UVInt64(v)
+	return nil
+}
+
+func (i *UVInt64) Val() uint64 {
+	return uint64(*i)
+}
+
+func (i *UVInt64) String() string {
+	return fmt.Sprint(*i)
+}
+
+// *SVInt implements Sophie interface and serializing as a zigzag-encoded vint,
+// so that negative numbers with small absolute values take few bytes.
+type SVInt int
+
+// Returns a new instance of *SVInt as a Sophier
+func NewSVInt() Sophier {
+	return new(SVInt)
+}
+
+// SophieWriter interface
+func (i SVInt) WriteTo(w Writer) error {
+	return writeUVarint(w, zigzag(int64(i)))
+}
+
+// Sizer interface
+func (i SVInt) EncodedLen() int {
+	return uvarintLen(zigzag(int64(i)))
+}
+
+// SophieReader interface
+func (i *SVInt) ReadFrom(r Reader, l int) error {
+	v, err := readUVarint(r)
+	if err != nil {
+		return err
+	}
+	*i = SVInt(unzigzag(v))
+	return nil
+}
+
+func (i *SVInt) Val() int {
+	return int(*i)
+}
+
+func (i *SVInt) String() string {
+	return fmt.Sprint(*i)
+}
+
+// *SVInt64 implements Sophie interface and serializing as a zigzag-encoded
+// vint.
+type SVInt64 int64
+
+// Returns a new instance of *SVInt64 as a Sophier
+func NewSVInt64() Sophier {
+	return new(SVInt64)
+}
+
+// SophieWriter interface
+func (i SVInt64) WriteTo(w Writer) error {
+	return writeUVarint(w, zigzag(int64(i)))
+}
+
+// Sizer interface
+func (i SVInt64) EncodedLen() int {
+	return uvarintLen(zigzag(int64(i)))
+}
+
+// SophieReader interface
+func (i *SVInt64) ReadFrom(r Reader, l int) error {
+	v, err := readUVarint(r)
+	if err != nil {
+		return err
+	}
+	*i = SVInt64(unzigzag(v))
+	return nil
+}
+
+func (i *SVInt64) Val() int64 {
+	return int64(*i)
+}
+
+func (i *SVInt64) String() string {
+	return fmt.Sprint(*i)
+}
+
+// *RawVInt64 implements Sophie interface and serializing as a vint.
+// It assumes the length to be known. Negative values always take 8 bytes.
+type RawVInt64 int64
+
+// Returns a new instance of *RawVInt64 as a Sophier
+func NewRawVInt64() Sophier {
+	return new(RawVInt64)
+}
+
+// SophieWriter interface
+func (i RawVInt64) WriteTo(w Writer) error {
+	return writeRawUint(w, uint64(i))
+}
+
+// Sizer interface
+func (i RawVInt64) EncodedLen() int {
+	return rawUintLen(uint64(i))
+}
+
+// SophieReader interface
+func (i *RawVInt64) ReadFrom(r Reader, l int) error {
+	v, err := readRawUint(r, l)
+	if err != nil {
+		return err
+	}
+	*i = RawVInt64(v)
+	return nil
+}
+
+func (i *RawVInt64) Val() int64 {
+	return int64(*i)
+}
+
+func (i *RawVInt64) String() string {
+	return fmt.Sprint(*i)
+}
+
+// *RawUVInt64 implements Sophie interface and serializing as a vint.
+// It assumes the length to be known.
+type RawUVInt64 uint64
+
+// Returns a new instance of *RawUVInt64 as a Sophier
+func NewRawUVInt64() Sophier {
+	return new(RawUVInt64)
+}
+
+// SophieWriter interface
+func (i RawUVInt64) WriteTo(w Writer) error {
+	return writeRawUint(w, uint64(i))
+}
+
+// Sizer interface
+func (i RawUVInt64) EncodedLen() int {
+	return rawUintLen(uint64(i))
+}
+
+// SophieReader interface
+func (i *RawUVInt64) ReadFrom(r Reader, l int) error {
+	v, err := readRawUint(r, l)
+	if err != nil {
+		return err
+	}
+	*i = RawUVInt64(v)
+	return nil
+}
+
+func (i *RawUVInt64) Val() uint64 {
+	return uint64(*i)
+}
+
+func (i *RawUVInt64) String() string {
+	return fmt.Sprint(*i)
+}
+
+// *RawSVInt64 implements Sophie interface and serializing as a zigzag-encoded
+// vint. It assumes the length to be known.
+type RawSVInt64 int64
+
+// Returns a new instance of *RawSVInt64 as a Sophier
+func NewRawSVInt64() Sophier {
+	return new(RawSVInt64)
+}
+
+// SophieWriter interface
+func (i RawSVInt64) WriteTo(w Writer) error {
+	return writeRawUint(w, zigzag(int64(i)))
+}
+
+// Sizer interface
+func (i RawSVInt64) EncodedLen() int {
+	return rawUintLen(zigzag(int64(i)))
+}
+
+// SophieReader interface
+func (i *RawSVInt64) ReadFrom(r Reader, l int) error {
+	v, err := readRawUint(r, l)
+	if err != nil {
+		return err
+	}
+	*i = RawSVInt64(unzigzag(v))
+	return nil
+}
+
+func (i *RawSVInt64) Val() int64 {
+	return int64(*i)
+}
+
+func (i *RawSVInt64) String() string {
+	return fmt.Sprint(*i)
+}
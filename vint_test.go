@@ -0,0 +1,124 @@
+package sophie
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/golangplus/bytes"
+	"github.com/golangplus/errors"
+	"github.com/golangplus/testing/assert"
+)
+
+func TestVInt_Negative(t *testing.T) {
+	var via, vib VInt
+	via = -1
+	readWrite(t, &via, &vib, 10)
+	assert.Equal(t, "vib", vib, via)
+	via = -1234567
+	readWrite(t, &via, &vib, 10)
+	assert.Equal(t, "vib", vib, via)
+}
+
+func TestVInt_Overflow(t *testing.T) {
+	var i VInt
+	bs := bytesp.Slice("\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01")
+	if err := i.ReadFrom(&bs, -1); !assert.Equal(t, "i.ReadFrom(&bs, -1)", errorsp.Cause(err), ErrBadFormat) {
+		t.Logf("err: %v", err)
+	}
+	bs = bytesp.Slice("\xff\xff\xff\xff\xff\xff\xff\xff\xff\x02")
+	if err := i.ReadFrom(&bs, -1); !assert.Equal(t, "i.ReadFrom(&bs, -1)", errorsp.Cause(err), ErrBadFormat) {
+		t.Logf("err: %v", err)
+	}
+	var u UVInt64
+	bs = bytesp.Slice("\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01")
+	assert.NoError(t, u.ReadFrom(&bs, -1))
+	assert.Equal(t, "u", u, UVInt64(math.MaxUint64))
+}
+
+func TestVInt64(t *testing.T) {
+	var a, b VInt64
+	for _, c := range []struct {
+		v VInt64
+		n int
+	}{
+		{0, 1}, {127, 1}, {128, 2}, {math.MaxInt64, 9}, {-1, 10}, {math.MinInt64, 10},
+	} {
+		a = c.v
+		readWrite(t, &a, &b, c.n)
+		assert.Equal(t, "b", b, a)
+	}
+}
+
+func TestUVInt64(t *testing.T) {
+	var a, b UVInt64
+	for _, c := range []struct {
+		v UVInt64
+		n int
+	}{
+		{0, 1}, {127, 1}, {128, 2}, {math.MaxInt64, 9}, {math.MaxUint64, 10},
+	} {
+		a = c.v
+		readWrite(t, &a, &b, c.n)
+		assert.Equal(t, "b", b, a)
+	}
+}
+
+func TestSVInt(t *testing.T) {
+	var a, b SVInt
+	for _, c := range []struct {
+		v SVInt
+		n int
+	}{
+		{0, 1}, {-1, 1}, {1, 1}, {-64, 1}, {63, 1}, {64, 2}, {-65, 2},
+	} {
+		a = c.v
+		readWrite(t, &a, &b, c.n)
+		assert.Equal(t, "b", b, a)
+	}
+
+	var a64, b64 SVInt64
+	for _, c := range []struct {
+		v SVInt64
+		n int
+	}{
+		{0, 1}, {-1, 1}, {math.MaxInt64, 10}, {math.MinInt64, 10},
+	} {
+		a64 = c.v
+		readWrite(t, &a64, &b64, c.n)
+		assert.Equal(t, "b64", b64, a64)
+	}
+}
+
+func TestSVInt_Truncated(t *testing.T) {
+	bs := bytesp.Slice("\x80")
+	var i SVInt64
+	if err := i.ReadFrom(&bs, -1); !assert.Equal(t, "i.ReadFrom(&bs, -1)", errorsp.Cause(err), io.ErrUnexpectedEOF) {
+		t.Logf("err: %v", err)
+	}
+}
+
+func TestRawVInt_Negative(t *testing.T) {
+	var a, b RawVInt
+	a = -1
+	readWrite(t, &a, &b, 8)
+	assert.Equal(t, "b", b, a)
+
+	var a64, b64 RawVInt64
+	a64 = math.MinInt64
+	readWrite(t, &a64, &b64, 8)
+	assert.Equal(t, "b64", b64, a64)
+
+	var sa, sb RawSVInt64
+	sa = -1
+	readWrite(t, &sa, &sb, 1)
+	assert.Equal(t, "sb", sb, sa)
+
+	var ua, ub RawUVInt64
+	ua = math.MaxUint64
+	readWrite(t, &ua, &ub, 8)
+	assert.Equal(t, "ub", ub, ua)
+
+	bs := bytesp.Slice("123456789")
+	assert.Equal(t, "ub.ReadFrom(&bs, 9)", errorsp.Cause(ub.ReadFrom(&bs, 9)), ErrBadFormat)
+}